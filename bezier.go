@@ -0,0 +1,300 @@
+package boardshapes
+
+import (
+	"math"
+	"slices"
+)
+
+// How many vertices away from a vertex are looked at to tell how sharply the path turns there.
+const CURVE_CORNER_SPAN = 4
+
+// Vertices where the path turns more sharply than this (in radians) are corners, which curves don't smooth over.
+const CURVE_CORNER_ANGLE = math.Pi / 3
+
+// How many times the fit of a curve is refined before it's split in two instead.
+const MAXIMUM_CURVE_REFINEMENTS = 4
+
+type CurvePoint struct {
+	X, Y float32
+}
+
+// A cubic Bezier curve from Start to End, pulled towards Control1 and then Control2.
+type CubicBezier struct {
+	Start, Control1, Control2, End CurvePoint
+}
+
+// Returns the point that is t (from 0 to 1) of the way along the curve.
+func (cb CubicBezier) At(t float64) (x, y float64) {
+	p := cubicBezier{
+		point{float64(cb.Start.X), float64(cb.Start.Y)},
+		point{float64(cb.Control1.X), float64(cb.Control1.Y)},
+		point{float64(cb.Control2.X), float64(cb.Control2.Y)},
+		point{float64(cb.End.X), float64(cb.End.Y)},
+	}.at(t)
+	return p.X, p.Y
+}
+
+func (a point) add(b point) point {
+	return point{a.X + b.X, a.Y + b.Y}
+}
+
+func (a point) sub(b point) point {
+	return point{a.X - b.X, a.Y - b.Y}
+}
+
+func (a point) scale(s float64) point {
+	return point{a.X * s, a.Y * s}
+}
+
+func (a point) dot(b point) float64 {
+	return a.X*b.X + a.Y*b.Y
+}
+
+func (a point) normalize() point {
+	length := math.Hypot(a.X, a.Y)
+	if length == 0 {
+		return a
+	}
+	return a.scale(1 / length)
+}
+
+func (a point) curvePoint() CurvePoint {
+	return CurvePoint{float32(a.X), float32(a.Y)}
+}
+
+type cubicBezier [4]point
+
+func (b cubicBezier) at(t float64) point {
+	s := 1 - t
+	return b[0].scale(s * s * s).
+		add(b[1].scale(3 * s * s * t)).
+		add(b[2].scale(3 * s * t * t)).
+		add(b[3].scale(t * t * t))
+}
+
+// The first derivative.
+func (b cubicBezier) velocity(t float64) point {
+	s := 1 - t
+	return b[1].sub(b[0]).scale(3 * s * s).
+		add(b[2].sub(b[1]).scale(6 * s * t)).
+		add(b[3].sub(b[2]).scale(3 * t * t))
+}
+
+// The second derivative.
+func (b cubicBezier) acceleration(t float64) point {
+	return b[2].sub(b[1].scale(2)).add(b[0]).scale(6 * (1 - t)).
+		add(b[3].sub(b[2].scale(2)).add(b[1]).scale(6 * t))
+}
+
+// Fits a path with cubic Bezier curves (using Philip J. Schneider's algorithm from Graphics Gems), so that no vertex
+// is further than the tolerance (in pixels) from the curves. Each curve starts where the previous one ended.
+// If closed is true, the last curve ends where the first one started. Works best on paths that haven't been optimized yet.
+func FitCurves(path []Vertex, closed bool, tolerance float64) []CubicBezier {
+	if len(path) < 2 {
+		return nil
+	}
+	points := make([]point, len(path))
+	for i, v := range path {
+		points[i] = point{float64(v.X), float64(v.Y)}
+	}
+	n := len(points)
+
+	fitted := make([]cubicBezier, 0)
+	corners := findCorners(points, closed)
+	switch {
+	case !closed:
+		corners = append([]int{0}, corners...)
+		if corners[len(corners)-1] != n-1 {
+			corners = append(corners, n-1)
+		}
+		for i := range len(corners) - 1 {
+			start, end := corners[i], corners[i+1]
+			run := points[start : end+1]
+			fitted = fitCubic(run, runTangent(run, false), runTangent(run, true), tolerance, fitted)
+		}
+	case len(corners) == 0:
+		// smooth all the way around, so the curves meet at the start without a kink
+		run := append(slices.Clone(points), points[0])
+		span := min(CURVE_CORNER_SPAN, n/2)
+		startTangent := points[span].sub(points[n-span]).normalize()
+		fitted = fitCubic(run, startTangent, startTangent.scale(-1), tolerance, fitted)
+	default:
+		for i, start := range corners {
+			end := corners[(i+1)%len(corners)]
+			var run []point
+			if end > start {
+				run = points[start : end+1]
+			} else {
+				run = append(slices.Clone(points[start:]), points[:end+1]...)
+			}
+			fitted = fitCubic(run, runTangent(run, false), runTangent(run, true), tolerance, fitted)
+		}
+	}
+
+	curves := make([]CubicBezier, len(fitted))
+	for i, b := range fitted {
+		curves[i] = CubicBezier{b[0].curvePoint(), b[1].curvePoint(), b[2].curvePoint(), b[3].curvePoint()}
+	}
+	return curves
+}
+
+// Returns the direction of the run at its start, or (pointing backwards) at its end,
+// looking a few vertices along so that pixel steps don't throw it off.
+func runTangent(run []point, atEnd bool) point {
+	span := min(CURVE_CORNER_SPAN, len(run)-1)
+	if atEnd {
+		return run[len(run)-1-span].sub(run[len(run)-1]).normalize()
+	}
+	return run[span].sub(run[0]).normalize()
+}
+
+// Finds the indices of the vertices where the path turns sharply, in order.
+func findCorners(points []point, closed bool) []int {
+	n := len(points)
+	if n < 2*CURVE_CORNER_SPAN+1 {
+		return nil
+	}
+
+	turns := make([]float64, n)
+	for i := range n {
+		if !closed && (i < CURVE_CORNER_SPAN || i >= n-CURVE_CORNER_SPAN) {
+			continue
+		}
+		before := points[i].sub(points[(i-CURVE_CORNER_SPAN+n)%n])
+		after := points[(i+CURVE_CORNER_SPAN)%n].sub(points[i])
+		turns[i] = math.Abs(math.Atan2(before.X*after.Y-before.Y*after.X, before.dot(after)))
+	}
+
+	corners := make([]int, 0)
+	for i, turn := range turns {
+		if turn <= CURVE_CORNER_ANGLE {
+			continue
+		}
+		// only keep the sharpest vertex of each corner
+		sharpest := true
+		for j := i - CURVE_CORNER_SPAN; j <= i+CURVE_CORNER_SPAN && sharpest; j++ {
+			if j == i || !closed && (j < 0 || j >= n) {
+				continue
+			}
+			other := turns[(j+n)%n]
+			sharpest = other < turn || other == turn && j > i
+		}
+		if sharpest {
+			corners = append(corners, i)
+		}
+	}
+	return corners
+}
+
+// Fits the points with curves, splitting them up until each curve is within the tolerance,
+// and appends the curves to fitted.
+func fitCubic(points []point, startTangent, endTangent point, tolerance float64, fitted []cubicBezier) []cubicBezier {
+	first, last := points[0], points[len(points)-1]
+	if len(points) == 2 {
+		distance := first.distanceTo(last) / 3
+		return append(fitted, cubicBezier{first, first.add(startTangent.scale(distance)), last.add(endTangent.scale(distance)), last})
+	}
+
+	u := chordLengthParameterize(points)
+	bezier := generateBezier(points, u, startTangent, endTangent)
+	maxError, split := maximumError(points, bezier, u)
+	if maxError <= tolerance*tolerance {
+		return append(fitted, bezier)
+	}
+
+	// if it's close, refining the parameters might be enough
+	if maxError <= 4*tolerance*tolerance {
+		for range MAXIMUM_CURVE_REFINEMENTS {
+			u = reparameterize(points, bezier, u)
+			bezier = generateBezier(points, u, startTangent, endTangent)
+			if maxError, split = maximumError(points, bezier, u); maxError <= tolerance*tolerance {
+				return append(fitted, bezier)
+			}
+		}
+	}
+
+	span := min(CURVE_CORNER_SPAN, split, len(points)-1-split)
+	centerTangent := points[split-span].sub(points[split+span]).normalize()
+	fitted = fitCubic(points[:split+1], startTangent, centerTangent, tolerance, fitted)
+	return fitCubic(points[split:], centerTangent.scale(-1), endTangent, tolerance, fitted)
+}
+
+// Assigns each point a parameter from 0 to 1 by how far along the path it is.
+func chordLengthParameterize(points []point) []float64 {
+	u := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		u[i] = u[i-1] + points[i].distanceTo(points[i-1])
+	}
+	if length := u[len(u)-1]; length > 0 {
+		for i := range u {
+			u[i] /= length
+		}
+	}
+	return u
+}
+
+// Finds the curve with the given end tangents that fits the points best (by least squares).
+func generateBezier(points []point, u []float64, startTangent, endTangent point) cubicBezier {
+	first, last := points[0], points[len(points)-1]
+
+	var c [2][2]float64
+	var x [2]float64
+	for i, p := range points {
+		t, s := u[i], 1-u[i]
+		b0, b1, b2, b3 := s*s*s, 3*s*s*t, 3*s*t*t, t*t*t
+		a0, a1 := startTangent.scale(b1), endTangent.scale(b2)
+
+		c[0][0] += a0.dot(a0)
+		c[0][1] += a0.dot(a1)
+		c[1][1] += a1.dot(a1)
+
+		rest := p.sub(first.scale(b0 + b1).add(last.scale(b2 + b3)))
+		x[0] += a0.dot(rest)
+		x[1] += a1.dot(rest)
+	}
+	c[1][0] = c[0][1]
+
+	alphaStart, alphaEnd := 0.0, 0.0
+	if det := c[0][0]*c[1][1] - c[1][0]*c[0][1]; det != 0 {
+		alphaStart = (x[0]*c[1][1] - x[1]*c[0][1]) / det
+		alphaEnd = (c[0][0]*x[1] - c[1][0]*x[0]) / det
+	}
+
+	// fall back to a third of the way along if the fit is nonsensical
+	length := first.distanceTo(last)
+	if epsilon := 1e-6 * length; alphaStart < epsilon || alphaEnd < epsilon {
+		alphaStart, alphaEnd = length/3, length/3
+	}
+
+	return cubicBezier{first, first.add(startTangent.scale(alphaStart)), last.add(endTangent.scale(alphaEnd)), last}
+}
+
+// Returns the largest squared distance from a point to where its parameter puts it on the curve,
+// and the index of that point.
+func maximumError(points []point, bezier cubicBezier, u []float64) (maxError float64, index int) {
+	index = len(points) / 2
+	for i := 1; i < len(points)-1; i++ {
+		d := bezier.at(u[i]).sub(points[i])
+		if e := d.dot(d); e > maxError {
+			maxError, index = e, i
+		}
+	}
+	return maxError, index
+}
+
+// Moves each parameter to where the curve is closest to its point, with a step of Newton's method.
+func reparameterize(points []point, bezier cubicBezier, u []float64) []float64 {
+	refined := make([]float64, len(u))
+	for i, p := range points {
+		t := u[i]
+		offset := bezier.at(t).sub(p)
+		velocity, acceleration := bezier.velocity(t), bezier.acceleration(t)
+		denominator := velocity.dot(velocity) + offset.dot(acceleration)
+		if denominator == 0 {
+			refined[i] = t
+			continue
+		}
+		refined[i] = max(0, min(1, t-offset.dot(velocity)/denominator))
+	}
+	return refined
+}
@@ -0,0 +1,72 @@
+package boardshapes
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitCurves(t *testing.T) {
+	// a circle traced pixel by pixel
+	path := make([]Vertex, 0)
+	for i := range 400 {
+		theta := 2 * math.Pi * float64(i) / 400
+		v := Vertex{uint16(math.Round(100 + 60*math.Cos(theta))), uint16(math.Round(100 + 60*math.Sin(theta)))}
+		if len(path) == 0 || path[len(path)-1] != v {
+			path = append(path, v)
+		}
+	}
+
+	const tolerance = 1.5
+	curves := FitCurves(path, true, tolerance)
+	if len(curves) == 0 || len(curves) > 16 {
+		t.Fatalf("FitCurves() returned %d curves, want between 1 and 16", len(curves))
+	}
+	if curves[0].Start != curves[len(curves)-1].End {
+		t.Errorf("FitCurves() ends at %v, want %v", curves[len(curves)-1].End, curves[0].Start)
+	}
+	for i := 1; i < len(curves); i++ {
+		if curves[i].Start != curves[i-1].End {
+			t.Fatalf("FitCurves() curve %d starts at %v, want %v", i, curves[i].Start, curves[i-1].End)
+		}
+	}
+	for _, curve := range curves {
+		for step := range 10 {
+			x, y := curve.At(float64(step) / 10)
+			if r := math.Hypot(x-100, y-100); math.Abs(r-60) > tolerance+1 {
+				t.Fatalf("FitCurves() curve passes through (%.1f, %.1f), %.1f from the center, want 60", x, y, r)
+			}
+		}
+	}
+}
+
+func TestFitCurves_Corners(t *testing.T) {
+	// the outline of a square, which shouldn't have its corners rounded off
+	path := make([]Vertex, 0)
+	for i := range 50 {
+		path = append(path, Vertex{uint16(i), 0})
+	}
+	for i := range 50 {
+		path = append(path, Vertex{50, uint16(i)})
+	}
+	for i := range 50 {
+		path = append(path, Vertex{uint16(50 - i), 50})
+	}
+	for i := range 50 {
+		path = append(path, Vertex{0, uint16(50 - i)})
+	}
+
+	curves := FitCurves(path, true, 1)
+	if len(curves) != 4 {
+		t.Fatalf("FitCurves() returned %d curves, want 4", len(curves))
+	}
+	for _, curve := range curves {
+		if (curve.Start.X != 0 && curve.Start.X != 50) || (curve.Start.Y != 0 && curve.Start.Y != 50) {
+			t.Errorf("FitCurves() curve starts at %v, want a corner", curve.Start)
+		}
+	}
+
+	open := FitCurves(path[:100], false, 1)
+	if len(open) != 2 || open[0].Start != (CurvePoint{0, 0}) || open[1].End != (CurvePoint{50, 49}) {
+		t.Errorf("FitCurves() open path = %v, want 2 curves from (0, 0) to (50, 49)", open)
+	}
+}
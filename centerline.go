@@ -0,0 +1,149 @@
+package boardshapes
+
+import (
+	"errors"
+)
+
+var ErrCenterlineTooShort = errors.New("region-to-centerline: centerline is too short")
+
+// Thins the region down to a skeleton one pixel wide (using Zhang-Suen thinning) and traces the longest path through it.
+// This is meant for strokes too thin to have an outline, such as ropes, rails and arrows drawn with a single line.
+// All vertices are relative to the top-left corner of the region's bounds.
+func (region *Region) CreateCenterline() (path []Vertex, err error) {
+	if len(*region) == 0 {
+		return nil, ErrRegionEmpty
+	}
+	regionBounds := region.GetBounds()
+
+	// add a pixel of padding on every side so neighbors never go out of bounds
+	width, height := regionBounds.Dx()+2, regionBounds.Dy()+2
+	skeleton := make([]bool, width*height)
+	for _, p := range *region {
+		skeleton[(int(p.Y)-regionBounds.Min.Y+1)*width+int(p.X)-regionBounds.Min.X+1] = true
+	}
+
+	thin(skeleton, width, height)
+
+	start := -1
+	for i, inSkeleton := range skeleton {
+		if inSkeleton {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return nil, ErrCenterlineTooShort
+	}
+
+	// the longest path starts at the furthest pixel from any pixel, and ends at the furthest pixel from there
+	end, _ := furthestSkeletonPixel(skeleton, width, start)
+	start, previous := furthestSkeletonPixel(skeleton, width, end)
+
+	path = make([]Vertex, 0)
+	for i := start; i != -1; i = previous[i] {
+		path = append(path, Vertex{uint16(i%width - 1), uint16(i/width - 1)})
+	}
+
+	if len(path) < 2 {
+		return nil, ErrCenterlineTooShort
+	}
+	return path, nil
+}
+
+// The offsets of the 8 neighbors of a pixel, clockwise from the one above it.
+func neighborOffsets(width int) [8]int {
+	return [8]int{-width, -width + 1, 1, width + 1, width, width - 1, -1, -width - 1}
+}
+
+// Zhang-Suen thinning, done in place.
+func thin(pixels []bool, width, height int) {
+	offsets := neighborOffsets(width)
+	toRemove := make([]int, 0)
+	for changed := true; changed; {
+		changed = false
+		for step := range 2 {
+			toRemove = toRemove[:0]
+			for y := 1; y < height-1; y++ {
+				for x := 1; x < width-1; x++ {
+					i := y*width + x
+					if !pixels[i] {
+						continue
+					}
+
+					var n [8]bool
+					count, transitions := 0, 0
+					for j, offset := range offsets {
+						n[j] = pixels[i+offset]
+						if n[j] {
+							count++
+						}
+					}
+					for j := range n {
+						if !n[j] && n[(j+1)%8] {
+							transitions++
+						}
+					}
+					if count < 2 || count > 6 || transitions != 1 {
+						continue
+					}
+
+					// n[0] is above, n[2] is right, n[4] is below, n[6] is left
+					if step == 0 && (n[0] && n[2] && n[4] || n[2] && n[4] && n[6]) {
+						continue
+					}
+					if step == 1 && (n[0] && n[2] && n[6] || n[0] && n[4] && n[6]) {
+						continue
+					}
+					toRemove = append(toRemove, i)
+				}
+			}
+			for _, i := range toRemove {
+				pixels[i] = false
+			}
+			changed = changed || len(toRemove) > 0
+		}
+	}
+}
+
+// Finds the skeleton pixel with the most steps between it and the start pixel.
+// Also returns the previous pixel of every visited pixel along the way back to the start (which has none, so -1).
+func furthestSkeletonPixel(pixels []bool, width, start int) (furthest int, previous map[int]int) {
+	offsets := neighborOffsets(width)
+	previous = map[int]int{start: -1}
+	queue := []int{start}
+	for len(queue) > 0 {
+		furthest = queue[0]
+		queue = queue[1:]
+		for _, offset := range offsets {
+			next := furthest + offset
+			if _, visited := previous[next]; pixels[next] && !visited {
+				previous[next] = furthest
+				queue = append(queue, next)
+			}
+		}
+	}
+	return furthest, previous
+}
+
+// Optimizes a path that doesn't loop back to its start, such as a centerline.
+func OptimizeOpenPathWithEpsilon(path []Vertex, epsilon float64) []Vertex {
+	// remove vertices in the middle of straight lines
+	optimizedPath := make([]Vertex, 0, len(path))
+	for i, v := range path {
+		if i > 0 && i < len(path)-1 {
+			x1, y1 := path[i-1].DirectionTo(v)
+			x2, y2 := v.DirectionTo(path[i+1])
+			if x1 == x2 && y1 == y2 {
+				continue
+			}
+		}
+		optimizedPath = append(optimizedPath, v)
+	}
+
+	//If epsilon is negative, skip RDP optimization
+	if epsilon < 0 || len(optimizedPath) <= MINIMUM_VERTICES_FOR_RDP {
+		return optimizedPath
+	}
+
+	return RDPOptimizer(optimizedPath, epsilon)
+}
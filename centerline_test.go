@@ -0,0 +1,64 @@
+package boardshapes
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRegion_CreateCenterline(t *testing.T) {
+	// an L shaped stroke, two pixels wide
+	region := make(Region, 0)
+	for i := range 40 {
+		region = append(region, Pixel{uint16(10 + i), 20}, Pixel{uint16(10 + i), 21})
+	}
+	for i := range 30 {
+		region = append(region, Pixel{48, uint16(22 + i)}, Pixel{49, uint16(22 + i)})
+	}
+
+	path, err := region.CreateCenterline()
+	if err != nil {
+		t.Fatalf("Region.CreateCenterline() error = %v", err)
+	}
+
+	first, last := path[0], path[len(path)-1]
+	if first.Y > last.Y {
+		first, last = last, first
+	}
+	// relative to the region's corner at (10, 20)
+	if first.X > 2 || first.Y > 1 {
+		t.Errorf("Region.CreateCenterline() starts at %v, want near (0, 0)", first)
+	}
+	if last.X < 38 || last.Y < 29 {
+		t.Errorf("Region.CreateCenterline() ends at %v, want near (39, 31)", last)
+	}
+	for i := 1; i < len(path); i++ {
+		if absDiff(path[i].X, path[i-1].X) > 1 || absDiff(path[i].Y, path[i-1].Y) > 1 {
+			t.Fatalf("Region.CreateCenterline() path jumps from %v to %v", path[i-1], path[i])
+		}
+	}
+}
+
+func TestCreateShapesWithCenterlines(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 200))
+	for y := range 200 {
+		for x := range 200 {
+			if x >= 20 && x < 180 && (y == 100 || y == 101) {
+				img.Set(x, y, Blue)
+			} else {
+				img.Set(x, y, White)
+			}
+		}
+	}
+
+	if data := CreateShapes(img, ShapeCreationOptions{}); len(data.Shapes) != 0 {
+		t.Errorf("CreateShapes() without centerlines created %d shapes, want 0", len(data.Shapes))
+	}
+
+	data := CreateShapes(img, ShapeCreationOptions{ExtractCenterlines: true})
+	if len(data.Shapes) != 1 {
+		t.Fatalf("CreateShapes() with centerlines created %d shapes, want 1", len(data.Shapes))
+	}
+	if shape := data.Shapes[0]; !shape.Open || len(shape.Path) != 2 {
+		t.Errorf("CreateShapes() created shape with open = %t and path %v, want an open straight line", shape.Open, shape.Path)
+	}
+}
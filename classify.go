@@ -0,0 +1,160 @@
+package boardshapes
+
+import (
+	"image/color"
+	"math"
+	"slices"
+)
+
+// Decides what color each pixel of an image is simplified to by [SimplifyImage].
+type ColorClassifier interface {
+	// Returns the color the pixel should be simplified to.
+	// Returning [Blank] marks the pixel as transparent, which is treated as [White]
+	// unless [ShapeCreationOptions.AllowWhite] is set.
+	Classify(c color.NRGBA) color.NRGBA
+	// Returns every color that Classify can return, other than [Blank].
+	Colors() []color.NRGBA
+}
+
+// Classifies pixels into white, black, red, green or blue using fixed thresholds.
+type ThresholdClassifier struct {
+	// Pixels with an alpha below this are transparent.
+	MinAlpha int
+	// Pixels whose channels are all within this distance of their average are grey,
+	// and become either white or black.
+	MaxGreySpread int
+	// Grey pixels with a channel brighter than this are white, the rest are black.
+	WhiteBrightness int
+	// Pixels where blue is brighter than green by less than this are still green,
+	// as long as red is darker than green.
+	GreenBlueTolerance int
+}
+
+// The classifier used when [ShapeCreationOptions.Classifier] is not set.
+var DefaultColorClassifier = ThresholdClassifier{
+	MinAlpha:           10,
+	MaxGreySpread:      10,
+	WhiteBrightness:    115,
+	GreenBlueTolerance: 10,
+}
+
+func (tc ThresholdClassifier) Classify(c color.NRGBA) color.NRGBA {
+	r, g, b, a := int(c.R), int(c.G), int(c.B), int(c.A)
+	avg := (r + g + b) / 3
+	switch {
+	case a < tc.MinAlpha:
+		return Blank
+	case max(absDiff(avg, r), absDiff(avg, g), absDiff(avg, b)) < tc.MaxGreySpread:
+		// todo: better way to detect black maybe
+		if max(r, g, b) > tc.WhiteBrightness {
+			return White
+		}
+		return Black
+	case r > g && r > b:
+		return Red
+	case g > r && (g > b || b-g < tc.GreenBlueTolerance):
+		return Green
+	case b > r && b > g:
+		return Blue
+	default:
+		return White
+	}
+}
+
+func (tc ThresholdClassifier) Colors() []color.NRGBA {
+	return []color.NRGBA{White, Black, Red, Green, Blue}
+}
+
+// Every color with a predefined variable, for use with [NewLabClassifier].
+var ExtendedColors = []color.NRGBA{White, Black, Red, Green, Blue, Cyan, Magenta, Yellow}
+
+// Classifies pixels as whichever of its colors is closest in the CIELAB color space,
+// which lines up with how different colors look to people much better than RGB does.
+type LabClassifier struct {
+	// Pixels with an alpha below this are transparent.
+	MinAlpha int
+	// Pixels further than this from every color become white. Distances of 0 or less are ignored.
+	MaxDistance float64
+	colors      []color.NRGBA
+	// the colors that pixels are compared against, which are usually the same as colors
+	labColors [][3]float64
+}
+
+// Creates a [LabClassifier] that picks between the given colors.
+func NewLabClassifier(colors []color.NRGBA, maxDistance float64) *LabClassifier {
+	return newLabClassifierWithReferences(colors, colors, maxDistance)
+}
+
+// Creates a [LabClassifier] that compares pixels against the reference colors,
+// but simplifies them to the color at the same index instead.
+func newLabClassifierWithReferences(colors, references []color.NRGBA, maxDistance float64) *LabClassifier {
+	lc := &LabClassifier{
+		MinAlpha:    DefaultColorClassifier.MinAlpha,
+		MaxDistance: maxDistance,
+		colors:      slices.Clone(colors),
+		labColors:   make([][3]float64, len(references)),
+	}
+	for i, c := range references {
+		lc.labColors[i] = ToLab(c)
+	}
+	return lc
+}
+
+func (lc *LabClassifier) Classify(c color.NRGBA) color.NRGBA {
+	if int(c.A) < lc.MinAlpha {
+		return Blank
+	}
+
+	lab := ToLab(c)
+	nearest, nearestDistance := White, math.Inf(1)
+	for i, other := range lc.labColors {
+		if d := LabDistance(lab, other); d < nearestDistance {
+			nearest, nearestDistance = lc.colors[i], d
+		}
+	}
+
+	if lc.MaxDistance > 0 && nearestDistance > lc.MaxDistance {
+		return White
+	}
+	return nearest
+}
+
+func (lc *LabClassifier) Colors() []color.NRGBA {
+	if !slices.Contains(lc.colors, White) {
+		// white is used for pixels that are too far from every color
+		return append([]color.NRGBA{White}, lc.colors...)
+	}
+	return slices.Clone(lc.colors)
+}
+
+// Converts the color (ignoring alpha) to the CIELAB color space, using the D65 white point.
+func ToLab(c color.NRGBA) [3]float64 {
+	linearize := func(v uint8) float64 {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	r, g, b := linearize(c.R), linearize(c.G), linearize(c.B)
+
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// Returns the distance between two CIELAB colors (CIE76 delta E).
+func LabDistance(a, b [3]float64) float64 {
+	dl, da, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
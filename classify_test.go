@@ -0,0 +1,73 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThresholdClassifier_Classify(t *testing.T) {
+	tests := []struct {
+		name string
+		c    color.NRGBA
+		want color.NRGBA
+	}{
+		{"transparent", color.NRGBA{200, 30, 30, 5}, Blank},
+		{"light grey", color.NRGBA{200, 205, 198, 255}, White},
+		{"dark grey", color.NRGBA{60, 64, 58, 255}, Black},
+		{"red marker", color.NRGBA{190, 40, 50, 255}, Red},
+		{"green marker", color.NRGBA{40, 150, 60, 255}, Green},
+		{"teal marker", color.NRGBA{30, 120, 125, 255}, Green},
+		{"blue marker", color.NRGBA{30, 60, 170, 255}, Blue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultColorClassifier.Classify(tt.c); got != tt.want {
+				t.Errorf("ThresholdClassifier.Classify(%v) = %v, want %v", tt.c, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabClassifier_Classify(t *testing.T) {
+	classifier := NewLabClassifier(ExtendedColors, 80)
+	tests := []struct {
+		name string
+		c    color.NRGBA
+		want color.NRGBA
+	}{
+		{"transparent", color.NRGBA{0, 200, 210, 0}, Blank},
+		{"cyan", color.NRGBA{20, 220, 230, 255}, Cyan},
+		{"magenta", color.NRGBA{210, 30, 200, 255}, Magenta},
+		{"yellow", color.NRGBA{240, 230, 40, 255}, Yellow},
+		{"black", color.NRGBA{20, 25, 20, 255}, Black},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifier.Classify(tt.c); got != tt.want {
+				t.Errorf("LabClassifier.Classify(%v) = %v, want %v", tt.c, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("too far", func(t *testing.T) {
+		classifier := NewLabClassifier([]color.NRGBA{Red}, 10)
+		if got := classifier.Classify(Blue); got != White {
+			t.Errorf("LabClassifier.Classify(%v) = %v, want %v", Blue, got, White)
+		}
+	})
+}
+
+func TestSimplifyImageWithClassifier(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.NRGBA{20, 220, 230, 255})
+	img.Set(1, 0, color.NRGBA{240, 230, 40, 255})
+	img.Set(2, 0, color.NRGBA{250, 250, 250, 255})
+
+	simplified := SimplifyImage(img, ShapeCreationOptions{Classifier: NewLabClassifier(ExtendedColors, 0)})
+	for x, want := range []color.NRGBA{Cyan, Yellow, White} {
+		if got := simplified.At(x, 0); got != want {
+			t.Errorf("SimplifyImage() pixel %d = %v, want %v", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var errOverwritesInput = errors.New("output would overwrite the input")
+var errNoMatches = errors.New("no files match the pattern")
+
+// An input to process when processing more than one, and where its output goes.
+type batchJob struct {
+	inputPath  string
+	outputPath string
+}
+
+type batchResult struct {
+	job batchJob
+	err error
+}
+
+// Whether there's more than one input to process: more than one path, a directory, or a glob pattern.
+func isBatch(inputs []string) bool {
+	if len(inputs) > 1 {
+		return true
+	}
+	if inputs[0] == "-" {
+		return false
+	}
+	if info, err := os.Stat(inputs[0]); err == nil {
+		return info.IsDir()
+	}
+	return isGlobPattern(inputs[0])
+}
+
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// Extensions of the files in input directories that the mode processes. Other files are left alone.
+func getInputExtensions() []string {
+	switch mode {
+	case "g", "generate", "s", "simplify", "d", "debug":
+		return []string{".png", ".jpg", ".jpeg"}
+	case "r", "reserialize", "v", "render":
+		return []string{".bshapes", ".jshapes"}
+	case "i", "import":
+		return []string{".svg", ".geojson"}
+	default:
+		return nil
+	}
+}
+
+// Processes every input, with as many at the same time as the worker count, then prints a summary.
+// Returns the exit code, which is 1 if any input failed.
+func runBatch(inputs []string) int {
+	if useStdOut {
+		log.Fatalln("can't write to stdout when processing more than one input")
+	}
+
+	jobs, results := findBatchJobs(inputs)
+
+	queue := make(chan batchJob)
+	done := make(chan batchResult)
+	var wg sync.WaitGroup
+	for range max(workerCount, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				done <- batchResult{job: job, err: processFile(job)}
+			}
+		}()
+	}
+	go func() {
+		for _, job := range jobs {
+			queue <- job
+		}
+		close(queue)
+		wg.Wait()
+		close(done)
+	}()
+
+	for result := range done {
+		if result.err != nil {
+			log.Printf("%s: %v\n", result.job.inputPath, result.err)
+		}
+		results = append(results, result)
+	}
+
+	if printBatchSummary(os.Stderr, results) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Prints how many of the inputs succeeded, then each that failed and why. Returns how many failed.
+func printBatchSummary(w io.Writer, results []batchResult) int {
+	failed := make([]batchResult, 0)
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+		}
+	}
+	slices.SortFunc(failed, func(a, b batchResult) int {
+		return strings.Compare(a.job.inputPath, b.job.inputPath)
+	})
+
+	fmt.Fprintf(w, "%d of %d inputs succeeded, %d failed\n", len(results)-len(failed), len(results), len(failed))
+	for _, result := range failed {
+		fmt.Fprintf(w, "  %s: %v\n", result.job.inputPath, result.err)
+	}
+	return len(failed)
+}
+
+// Finds the files to process and where their outputs go. Inputs that can't be processed at all,
+// such as patterns that match nothing or files whose output would overwrite something, are returned as failures.
+func findBatchJobs(inputs []string) (jobs []batchJob, failures []batchResult) {
+	extensions := getInputExtensions()
+	outputExtension := getOutputExtension()
+	outputDirectory, _ := filepath.Abs(outputPath)
+	// from absolute output paths to the inputs they're for
+	outputs := make(map[string]string)
+
+	addJob := func(inputPath, relativePath string) {
+		job := batchJob{inputPath: inputPath}
+		if outputPath != "" {
+			job.outputPath = filepath.Join(outputPath, replaceExtension(relativePath, outputExtension))
+		} else {
+			job.outputPath = replaceExtension(inputPath, outputExtension)
+		}
+
+		absInput, _ := filepath.Abs(inputPath)
+		absOutput, _ := filepath.Abs(job.outputPath)
+		if absInput == absOutput {
+			failures = append(failures, batchResult{job: job, err: errOverwritesInput})
+			return
+		}
+		if other, ok := outputs[absOutput]; ok {
+			failures = append(failures, batchResult{job: job, err: fmt.Errorf("output would overwrite the output of %s", other)})
+			return
+		}
+		outputs[absOutput] = inputPath
+		jobs = append(jobs, job)
+	}
+
+	addPath := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			failures = append(failures, batchResult{job: batchJob{inputPath: path}, err: err})
+			return
+		}
+		if !info.IsDir() {
+			addJob(path, filepath.Base(path))
+			return
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// don't process outputs from earlier runs
+				if abs, _ := filepath.Abs(file); outputPath != "" && abs == outputDirectory && file != path {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !slices.Contains(extensions, strings.ToLower(filepath.Ext(file))) {
+				return nil
+			}
+			relativePath, err := filepath.Rel(path, file)
+			if err != nil {
+				return err
+			}
+			addJob(file, relativePath)
+			return nil
+		})
+		if err != nil {
+			failures = append(failures, batchResult{job: batchJob{inputPath: path}, err: err})
+		}
+	}
+
+	for _, input := range inputs {
+		if _, err := os.Stat(input); err != nil && isGlobPattern(input) {
+			matches, err := filepath.Glob(input)
+			if err == nil && len(matches) == 0 {
+				err = errNoMatches
+			}
+			if err != nil {
+				failures = append(failures, batchResult{job: batchJob{inputPath: input}, err: err})
+			}
+			for _, match := range matches {
+				addPath(match)
+			}
+			continue
+		}
+		addPath(input)
+	}
+	return jobs, failures
+}
+
+// Processes one input, writing its output to a file. If it fails, no output file is left behind.
+func processFile(job batchJob) error {
+	if err := os.MkdirAll(filepath.Dir(job.outputPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(job.outputPath)
+	if err != nil {
+		return err
+	}
+
+	err = processInput(job.inputPath, job.outputPath, f)
+	closeErr := f.Close()
+	if err != nil {
+		os.Remove(job.outputPath)
+		return err
+	}
+	return closeErr
+}
+
+func replaceExtension(path, extension string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + extension
+}
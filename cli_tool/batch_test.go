@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization"
+)
+
+// Sets the flags that batches depend on until the test ends.
+func setBatchFlags(t *testing.T, batchMode, format, output string) {
+	oldMode, oldFormat, oldOutput := mode, outputFormat, outputPath
+	mode, outputFormat, outputPath = batchMode, format, output
+	t.Cleanup(func() {
+		mode, outputFormat, outputPath = oldMode, oldFormat, oldOutput
+	})
+}
+
+// Creates the files, and the directories they're in, under dir.
+func createFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindBatchJobs(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		files  []string
+		inputs []string
+		// relative to the test's directory, or empty to write outputs next to their inputs
+		output string
+		// the inputs of the jobs and failures, relative to the test's directory
+		wantJobs     []string
+		wantFailures []string
+		wantErr      error
+	}{
+		{
+			name:     "directory",
+			mode:     "generate",
+			files:    []string{"in/a.png", "in/b.jpg", "in/sub/c.jpeg", "in/notes.txt"},
+			inputs:   []string{"in"},
+			output:   "out",
+			wantJobs: []string{"in/a.png", "in/b.jpg", "in/sub/c.jpeg"},
+		},
+		{
+			name:         "outputs with the same name",
+			mode:         "generate",
+			files:        []string{"in/a.png", "in/a.jpg"},
+			inputs:       []string{"in"},
+			wantJobs:     []string{"in/a.jpg"},
+			wantFailures: []string{"in/a.png"},
+		},
+		{
+			name:         "outputs with the same name from different inputs",
+			mode:         "generate",
+			files:        []string{"one/a.png", "two/a.png"},
+			inputs:       []string{"one", "two"},
+			output:       "out",
+			wantJobs:     []string{"one/a.png"},
+			wantFailures: []string{"two/a.png"},
+		},
+		{
+			name:         "output overwrites its input",
+			mode:         "reserialize",
+			files:        []string{"in/a.jshapes"},
+			inputs:       []string{"in/a.jshapes", "in/a.jshapes"},
+			wantFailures: []string{"in/a.jshapes", "in/a.jshapes"},
+			wantErr:      errOverwritesInput,
+		},
+		{
+			name:     "output directory inside the input directory",
+			mode:     "generate",
+			files:    []string{"in/a.png", "in/out/b.png"},
+			inputs:   []string{"in"},
+			output:   "in/out",
+			wantJobs: []string{"in/a.png"},
+		},
+		{
+			name:     "glob pattern",
+			mode:     "generate",
+			files:    []string{"in/a.png", "in/b.png", "in/c.jpg"},
+			inputs:   []string{"in/*.png"},
+			output:   "out",
+			wantJobs: []string{"in/a.png", "in/b.png"},
+		},
+		{
+			name:         "glob pattern that matches nothing",
+			mode:         "generate",
+			files:        []string{"in/a.png"},
+			inputs:       []string{"in/*.jpg"},
+			output:       "out",
+			wantFailures: []string{"in/*.jpg"},
+			wantErr:      errNoMatches,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := make(map[string]string)
+			for _, file := range tt.files {
+				files[file] = ""
+			}
+			createFiles(t, dir, files)
+			output := ""
+			if tt.output != "" {
+				output = filepath.Join(dir, tt.output)
+			}
+			setBatchFlags(t, tt.mode, "json", output)
+
+			inputs := make([]string, len(tt.inputs))
+			for i, input := range tt.inputs {
+				inputs[i] = filepath.Join(dir, input)
+			}
+			jobs, failures := findBatchJobs(inputs)
+
+			relative := func(path string) string {
+				rel, _ := filepath.Rel(dir, path)
+				return filepath.ToSlash(rel)
+			}
+			gotJobs := make([]string, 0)
+			for _, job := range jobs {
+				gotJobs = append(gotJobs, relative(job.inputPath))
+			}
+			gotFailures := make([]string, 0)
+			for _, failure := range failures {
+				gotFailures = append(gotFailures, relative(failure.job.inputPath))
+				if tt.wantErr != nil && !errors.Is(failure.err, tt.wantErr) {
+					t.Errorf("findBatchJobs() failure for %s = %v, want %v", failure.job.inputPath, failure.err, tt.wantErr)
+				}
+			}
+			slices.Sort(gotJobs)
+			if !slices.Equal(gotJobs, tt.wantJobs) {
+				t.Errorf("findBatchJobs() jobs = %v, want %v", gotJobs, tt.wantJobs)
+			}
+			if !slices.Equal(gotFailures, tt.wantFailures) {
+				t.Errorf("findBatchJobs() failures = %v, want %v", gotFailures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestPrintBatchSummary(t *testing.T) {
+	failure := errors.New("failed")
+	result := func(input string, err error) batchResult {
+		return batchResult{job: batchJob{inputPath: input}, err: err}
+	}
+	tests := []struct {
+		name       string
+		results    []batchResult
+		wantFailed int
+		wantLines  []string
+	}{
+		{
+			name:       "all succeeded",
+			results:    []batchResult{result("a", nil), result("b", nil)},
+			wantFailed: 0,
+			wantLines:  []string{"2 of 2 inputs succeeded, 0 failed"},
+		},
+		{
+			name:       "some failed",
+			results:    []batchResult{result("c", failure), result("a", nil), result("b", failure)},
+			wantFailed: 2,
+			wantLines:  []string{"1 of 3 inputs succeeded, 2 failed", "  b: failed", "  c: failed"},
+		},
+		{
+			name:       "all failed",
+			results:    []batchResult{result("a", failure)},
+			wantFailed: 1,
+			wantLines:  []string{"0 of 1 inputs succeeded, 1 failed", "  a: failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w bytes.Buffer
+			if failed := printBatchSummary(&w, tt.results); failed != tt.wantFailed {
+				t.Errorf("printBatchSummary() = %d, want %d", failed, tt.wantFailed)
+			}
+			if lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n"); !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("printBatchSummary() printed %q, want %q", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestRunBatchPartialFailure(t *testing.T) {
+	var valid bytes.Buffer
+	if err := serialization.JsonSerialize(&valid, &boardshapes.BoardshapesData{Version: boardshapes.VERSION}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	createFiles(t, dir, map[string]string{
+		"in/valid.jshapes":   valid.String(),
+		"in/invalid.jshapes": "not shapes",
+	})
+	output := filepath.Join(dir, "out")
+	setBatchFlags(t, "reserialize", "binary", output)
+
+	if code := runBatch([]string{filepath.Join(dir, "in")}); code != 1 {
+		t.Errorf("runBatch() = %d, want 1", code)
+	}
+	if _, err := os.Stat(filepath.Join(output, "valid.bshapes")); err != nil {
+		t.Errorf("runBatch() didn't write the valid input's output: %v", err)
+	}
+	// failed inputs don't leave anything behind
+	if _, err := os.Stat(filepath.Join(output, "invalid.bshapes")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("runBatch() left the invalid input's output behind: %v", err)
+	}
+}
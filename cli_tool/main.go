@@ -6,12 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 
@@ -22,10 +24,37 @@ import (
 var resizeImage string
 var mode string
 var binaryOutput bool
+var compressOutput bool
+var outputFormat string
+var svgEmbedImages bool
+var tiledTileSize string
+var tags tagsFlag
+var recordCreationTime bool
+var annotateRender bool
+var debugOverlayOnly bool
 var outputPath string
 var useStdOut bool
 var optimizeShapeEpsilon float64
+var noColorSeparation bool
+var allowWhite bool
+var preserveColor bool
+var keepSmallRegions bool
+var minRegionSize int
+var discoverColors int
+var perspective string
+var normalizeIllumination bool
+var illuminationKernelSize int
+var glareCompensation float64
+var extractCenterlines bool
+var recognizePrimitives bool
+var curveTolerance float64
 var maxInputLength int
+var workerCount int
+var serveAddress string
+
+// parsed from the flags once in main, before any input is processed
+var shapeCreationOptions boardshapes.ShapeCreationOptions
+var tiledOptions *serialization.TiledOptions
 
 func init() {
 	const resizeFlagDescription = "Resize any input image to fit a specific size while maintaining aspect ratio. " +
@@ -39,7 +68,15 @@ func init() {
 		"- \"r\"/\"reserialize\" -> Deserialize data from a Boardshapes data file and then output the data after serializing it again. " +
 		"Useful for converting between binary and JSON formats or upgrading old Boardshapes data to the latest version." +
 		"- \"s\"/\"simplify\" -> Simplifies the color palette of an image file, giving you a preview of what color " +
-		"each pixel is classified as when generating shapes."
+		"each pixel is classified as when generating shapes." +
+		"- \"i\"/\"import\" -> Build shapes from the filled shapes of an SVG file, or the polygons and lines of a " +
+		"GeoJSON file, and output serialized Boardshapes data." +
+		"- \"v\"/\"render\" -> Deserialize data from a Boardshapes data file and draw its shapes to an image." +
+		"- \"d\"/\"debug\" -> Generate shapes from an image file and output an image of each stage of doing so: " +
+		"the prepared (perspective corrected, resized and evened out) image, the simplified image, and the region " +
+		"boundaries, traced outlines and optimized paths drawn over the prepared image. Useful for tuning options such as the epsilon." +
+		"- \"serve\" -> Serve generate, simplify and reserialize over HTTP at the address from the addr flag, " +
+		"instead of processing input files. See the server package for the endpoints and their options."
 	flag.StringVar(&mode, "m", "generate", modeFlagDescription)
 	flag.StringVar(&mode, "mode", "generate", modeFlagDescription)
 
@@ -47,7 +84,45 @@ func init() {
 	flag.BoolVar(&binaryOutput, "b", false, binaryFlagDescription)
 	flag.BoolVar(&binaryOutput, "binary", false, binaryFlagDescription)
 
-	const outputFileFlagDescription = "Path to the output file"
+	const compressFlagDescription = "Compresses binary output."
+	flag.BoolVar(&compressOutput, "z", false, compressFlagDescription)
+	flag.BoolVar(&compressOutput, "compress", false, compressFlagDescription)
+
+	const formatFlagDescription = "Format to serialize shape data to: \"json\", \"binary\", \"svg\", \"geojson\", " +
+		"or \"tmj\"/\"tmx\" for a Tiled map. If not specified, it is picked from the output file's extension " +
+		"(\".svg\", \".geojson\", \".tmj\" or \".tmx\"), or JSON otherwise."
+	flag.StringVar(&outputFormat, "f", "", formatFlagDescription)
+	flag.StringVar(&outputFormat, "format", "", formatFlagDescription)
+
+	const svgEmbedImagesFlagDescription = "Embeds each shape's image in SVG output, in a layer below the shapes."
+	flag.BoolVar(&svgEmbedImages, "svg-images", false, svgEmbedImagesFlagDescription)
+
+	const tiledTileSizeFlagDescription = "Size of the tiles in Tiled map output. " +
+		"Value should be in the format [width]x[height], or a single number for square tiles."
+	flag.StringVar(&tiledTileSize, "tile-size", "32x32", tiledTileSizeFlagDescription)
+
+	const tagFlagDescription = "Adds a tag to the metadata of generated or reserialized data, in the format key=value. " +
+		"Can be given more than once."
+	flag.Var(&tags, "t", tagFlagDescription)
+	flag.Var(&tags, "tag", tagFlagDescription)
+
+	const timestampFlagDescription = "Records when the shapes were generated in the metadata of generated data. " +
+		"Left out by default, so generating shapes from the same image always gives the same output."
+	flag.BoolVar(&recordCreationTime, "timestamp", false, timestampFlagDescription)
+
+	const annotateFlagDescription = "Draws outlines, vertices, shape numbers and a color legend over rendered shapes."
+	flag.BoolVar(&annotateRender, "a", false, annotateFlagDescription)
+	flag.BoolVar(&annotateRender, "annotate", false, annotateFlagDescription)
+
+	const overlayFlagDescription = "In debug mode, outputs only the overlay instead of putting it next to the prepared and simplified images."
+	flag.BoolVar(&debugOverlayOnly, "overlay", false, overlayFlagDescription)
+
+	const serveAddressFlagDescription = "The address to listen on in serve mode."
+	flag.StringVar(&serveAddress, "addr", ":8080", serveAddressFlagDescription)
+
+	const outputFileFlagDescription = "Path to the output file. When processing more than one input, the directory " +
+		"to write outputs to instead, with the same directory structure as the inputs. If not specified then, " +
+		"each output is written next to its input."
 	flag.StringVar(&outputPath, "o", "", outputFileFlagDescription)
 	flag.StringVar(&outputPath, "output", "", outputFileFlagDescription)
 
@@ -62,53 +137,192 @@ func init() {
 	flag.Float64Var(&optimizeShapeEpsilon, "e", 0.0, optimizeShapeEpsilonDescription)
 	flag.Float64Var(&optimizeShapeEpsilon, "epsilon", 0.0, optimizeShapeEpsilonDescription)
 
+	const noColorSeparationFlagDescription = "Treats touching pixels of different colors as one region, " +
+		"instead of separating them by color."
+	flag.BoolVar(&noColorSeparation, "no-color-separation", false, noColorSeparationFlagDescription)
+
+	const allowWhiteFlagDescription = "Keeps white pixels (usually the board) instead of treating them as empty space."
+	flag.BoolVar(&allowWhite, "allow-white", false, allowWhiteFlagDescription)
+
+	const preserveColorFlagDescription = "Keeps the original colors of the pixels in each shape's image, " +
+		"instead of filling it with the shape's color."
+	flag.BoolVar(&preserveColor, "preserve-color", false, preserveColorFlagDescription)
+
+	const keepSmallRegionsFlagDescription = "Keeps regions smaller than the minimum region size instead of leaving them out."
+	flag.BoolVar(&keepSmallRegions, "keep-small-regions", false, keepSmallRegionsFlagDescription)
+
+	minRegionSizeFlagDescription := fmt.Sprintf("Regions with fewer pixels than this are left out, unless small regions "+
+		"are kept. Will use the default of %d if not specified or set to 0.", boardshapes.DEFAULT_MIN_REGION_SIZE)
+	flag.IntVar(&minRegionSize, "min-region-size", 0, minRegionSizeFlagDescription)
+
+	const discoverColorsFlagDescription = "If greater than 0, discovers up to this many ink colors from the image " +
+		"instead of using the default palette."
+	flag.IntVar(&discoverColors, "discover-colors", 0, discoverColorsFlagDescription)
+
+	const perspectiveFlagDescription = "Corrects the perspective of the image before anything else. " +
+		"Value should be \"auto\" to find the corners of the board, or the corners clockwise from the top-left " +
+		"in the format x1,y1,x2,y2,x3,y3,x4,y4. If the perspective can't be corrected, the image is used as-is."
+	flag.StringVar(&perspective, "perspective", "", perspectiveFlagDescription)
+
+	const illuminationFlagDescription = "Evens out the lighting of the image (shadows, glare and white balance) " +
+		"before simplifying it."
+	flag.BoolVar(&normalizeIllumination, "illumination", false, illuminationFlagDescription)
+
+	illuminationKernelFlagDescription := fmt.Sprintf("Sets the size in pixels of the area used to estimate the board's "+
+		"brightness when evening out the lighting. Will use the default of %d if not specified or set to 0.",
+		boardshapes.DEFAULT_ILLUMINATION_KERNEL_SIZE)
+	flag.IntVar(&illuminationKernelSize, "illumination-kernel", 0, illuminationKernelFlagDescription)
+
+	const glareFlagDescription = "Sets how much glare (0 to 1) is removed when evening out the lighting."
+	flag.Float64Var(&glareCompensation, "glare", 0, glareFlagDescription)
+
+	const centerlinesFlagDescription = "Turns regions too thin to have an outline into open paths along their " +
+		"centerline, instead of leaving them out."
+	flag.BoolVar(&extractCenterlines, "centerlines", false, centerlinesFlagDescription)
+
+	const primitivesFlagDescription = "Recognizes which geometric primitive (circle, rectangle, etc.) each shape is."
+	flag.BoolVar(&recognizePrimitives, "primitives", false, primitivesFlagDescription)
+
+	const curveToleranceFlagDescription = "If greater than 0, also fits each shape with smooth curves " +
+		"that stray no further than this (in pixels) from it."
+	flag.Float64Var(&curveTolerance, "curve-tolerance", 0, curveToleranceFlagDescription)
+
 	const maxInputLengthDescription = "Sets the maximum number of bytes received through standard input. " +
 		"If set to a non-zero value, the program will continue reading from standard input until " +
 		"the limit is reached or EOF, rather than only reading until EOF."
 	flag.IntVar(&maxInputLength, "l", 0, maxInputLengthDescription)
 	flag.IntVar(&maxInputLength, "length", 0, maxInputLengthDescription)
+
+	const workerCountDescription = "Sets how many inputs are processed at the same time when processing more than one. " +
+		"More than one input is processed when given more than one path, a directory, or a glob pattern such as \"photos/*.jpg\"."
+	flag.IntVar(&workerCount, "j", runtime.NumCPU(), workerCountDescription)
+	flag.IntVar(&workerCount, "jobs", runtime.NumCPU(), workerCountDescription)
 }
 
 func main() {
 	flag.Parse()
 
+	if mode == "serve" {
+		serve()
+		return
+	}
+
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		log.Fatalln("no input file specified")
+	}
+	// a bad flag should stop everything before any input is processed, rather than fail every input
+	var err error
+	if shapeCreationOptions, err = getShapeCreationOptions(); err != nil {
+		log.Fatalln(err)
+	}
+	if tiledOptions, err = getTiledOptions(); err != nil {
+		log.Fatalln(err)
+	}
+	if isBatch(inputs) {
+		os.Exit(runBatch(inputs))
+	}
+
 	w, shouldClose := getOutputWriter()
+	err = processInput(inputs[0], outputPath, w)
 	if shouldClose {
-		defer w.Close()
+		w.Close()
 	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Performs the operation of the mode on one input, writing the result to w.
+// The output path is only used to pick the format of output images.
+func processInput(inputPath, outputPath string, w io.Writer) (err error) {
+	// the helpers panic when something goes wrong with the input
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
 
 	switch mode {
 	case "g", "generate":
-		img := getInputImage()
-		boardShapesData := boardshapes.CreateShapes(img, boardshapes.ShapeCreationOptions{
-			EpsilonRDP: optimizeShapeEpsilon,
-		})
+		img, sourceWidth, sourceHeight := getInputImageAndSourceSize(inputPath)
+		boardShapesData := boardshapes.CreateShapes(img, shapeCreationOptions)
+		boardShapesData.SetSourceSize(sourceWidth, sourceHeight)
+		addTags(boardShapesData)
 
 		serializeDataToWriter(w, boardShapesData)
 	case "s", "simplify":
-		img := getInputImage()
-		outputSimplifiedImageToWriter(w, img)
+		img := getInputImage(inputPath)
+		outputSimplifiedImageToWriter(w, img, outputPath)
+	case "i", "import":
+		boardShapesData := importInputData(inputPath)
+		serializeDataToWriter(w, boardShapesData)
 	case "r", "reserialize":
 		var boardShapesData *boardshapes.BoardshapesData
-		boardShapesData = getInputData()
+		boardShapesData = getInputData(inputPath)
+		addTags(boardShapesData)
 		serializeDataToWriter(w, boardShapesData)
+	case "v", "render":
+		boardShapesData := getInputData(inputPath)
+		outputRenderedImageToWriter(w, boardShapesData, outputPath)
+	case "d", "debug":
+		img := getInputImage(inputPath)
+		outputDebugImageToWriter(w, img, outputPath)
 	default:
-		log.Fatalf("unknown mode: %s\n", mode)
+		return fmt.Errorf("unknown mode: %s", mode)
 	}
-
+	return nil
 }
 
 func serializeDataToWriter(w io.Writer, boardShapesData *boardshapes.BoardshapesData) {
-	if binaryOutput {
-		err := serialization.BinarySerialize(w, boardShapesData, nil)
-		if err != nil {
-			panic(err)
+	var err error
+	switch getOutputFormat() {
+	case "binary":
+		options := serialization.DefaultOptions
+		if compressOutput {
+			options.Compression = serialization.COMPRESSION_FLATE
 		}
-	} else {
-		err := serialization.JsonSerialize(w, boardShapesData)
-		if err != nil {
-			panic(err)
+		err = serialization.BinarySerialize(w, boardShapesData, &options)
+	case "svg":
+		err = serialization.SvgSerialize(w, boardShapesData, &serialization.SvgOptions{EmbedImages: svgEmbedImages})
+	case "geojson":
+		err = serialization.GeoJsonSerialize(w, boardShapesData)
+	case "tmj", "tmx":
+		err = serialization.TiledSerialize(w, boardShapesData, tiledOptions)
+	default:
+		err = serialization.JsonSerialize(w, boardShapesData)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+func getOutputFormat() string {
+	switch outputFormat {
+	case "json", "binary", "svg", "geojson", "tmj", "tmx":
+		return outputFormat
+	case "":
+		if binaryOutput {
+			return "binary"
 		}
+		switch strings.ToLower(filepath.Ext(outputPath)) {
+		case ".svg":
+			return "svg"
+		case ".geojson":
+			return "geojson"
+		case ".tmj":
+			return "tmj"
+		case ".tmx":
+			return "tmx"
+		}
+		return "json"
+	default:
+		log.Fatalf("unknown output format: %s\n", outputFormat)
+		return ""
 	}
 }
 
@@ -137,29 +351,36 @@ func getOutputWriter() (w io.WriteCloser, shouldClose bool) {
 }
 
 func getDefaultOutputFilename() string {
+	return "output" + getOutputExtension()
+}
+
+func getOutputExtension() string {
 	switch mode {
-	case "g", "generate", "r", "reserialize":
-		if binaryOutput {
-			return "output.bshapes"
-		} else {
-			return "output.jshapes"
+	case "g", "generate", "r", "reserialize", "i", "import":
+		switch getOutputFormat() {
+		case "binary":
+			return ".bshapes"
+		case "svg":
+			return ".svg"
+		case "geojson":
+			return ".geojson"
+		case "tmj":
+			return ".tmj"
+		case "tmx":
+			return ".tmx"
+		default:
+			return ".jshapes"
 		}
-	case "s", "simplify":
-		return "output.png"
+	case "s", "simplify", "v", "render", "d", "debug":
+		return ".png"
 	default:
 		log.Fatalf("unknown mode: %s\n", mode)
 		return ""
 	}
 }
 
-func getInputReader() io.ReadSeeker {
-	args := flag.Args()
-
-	if len(args) == 0 {
-		panic(errors.New("no input file specified"))
-	}
-	stdInCheck := args[0]
-	if stdInCheck == "-" {
+func getInputReader(inputPath string) io.ReadSeeker {
+	if inputPath == "-" {
 		var r io.Reader = os.Stdin
 		if maxInputLength > 0 {
 			r = io.LimitReader(r, int64(maxInputLength))
@@ -172,24 +393,129 @@ func getInputReader() io.ReadSeeker {
 		return bytes.NewReader(data)
 	}
 
-	fileName := strings.Join(args, " ")
-	f, err := os.Open(fileName)
+	data, err := os.ReadFile(inputPath)
 	if err != nil {
 		panic(err)
 	}
-	return f
+	return bytes.NewReader(data)
 }
 
-func getInputImage() image.Image {
-	r := getInputReader()
+func getInputImage(inputPath string) image.Image {
+	img, _, _ := getInputImageAndSourceSize(inputPath)
+	return img
+}
+
+// Also returns the size of the image before it was resized.
+func getInputImageAndSourceSize(inputPath string) (image.Image, int, int) {
+	r := getInputReader(inputPath)
 
 	img := decodeImageFromFile(r)
-	img = resize(img)
-	return img
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	return resize(img), width, height
+}
+
+// Tags given with the tag flag, which can be given more than once.
+type tagsFlag map[string]string
+
+func (t *tagsFlag) String() string {
+	return fmt.Sprint(map[string]string(*t))
+}
+
+func (t *tagsFlag) Set(value string) error {
+	key, tagValue, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return errors.New("tag should be in the format key=value")
+	}
+	if *t == nil {
+		*t = make(tagsFlag)
+	}
+	(*t)[key] = tagValue
+	return nil
+}
+
+func addTags(data *boardshapes.BoardshapesData) {
+	if len(tags) == 0 {
+		return
+	}
+	if data.Metadata == nil {
+		data.Metadata = &boardshapes.Metadata{}
+	}
+	if data.Metadata.Tags == nil {
+		data.Metadata.Tags = make(map[string]string)
+	}
+	for key, value := range tags {
+		data.Metadata.Tags[key] = value
+	}
+}
+
+func getTiledOptions() (*serialization.TiledOptions, error) {
+	options := serialization.DefaultTiledOptions
+	if getOutputFormat() == "tmx" {
+		options.Format = serialization.TILED_TMX
+	}
+	width, height, found := strings.Cut(tiledTileSize, "x")
+	if !found {
+		height = width
+	}
+	var err error
+	if options.TileWidth, err = strconv.Atoi(width); err != nil {
+		return nil, fmt.Errorf("invalid tile size: %s", tiledTileSize)
+	}
+	if options.TileHeight, err = strconv.Atoi(height); err != nil {
+		return nil, fmt.Errorf("invalid tile size: %s", tiledTileSize)
+	}
+	return &options, nil
 }
 
-func getInputData() *boardshapes.BoardshapesData {
-	r := getInputReader()
+func getShapeCreationOptions() (boardshapes.ShapeCreationOptions, error) {
+	options := boardshapes.ShapeCreationOptions{
+		NoColorSeparation:   noColorSeparation,
+		AllowWhite:          allowWhite,
+		PreserveColor:       preserveColor,
+		KeepSmallRegions:    keepSmallRegions,
+		EpsilonRDP:          optimizeShapeEpsilon,
+		MinRegionSize:       minRegionSize,
+		DiscoverColors:      discoverColors,
+		ExtractCenterlines:  extractCenterlines,
+		RecognizePrimitives: recognizePrimitives,
+		CurveTolerance:      curveTolerance,
+		RecordCreationTime:  recordCreationTime,
+	}
+
+	switch perspective {
+	case "":
+	case "auto":
+		options.Perspective = &boardshapes.PerspectiveOptions{}
+	default:
+		coordinates := strings.Split(perspective, ",")
+		if len(coordinates) != 8 {
+			return options, fmt.Errorf("invalid perspective corners: %s", perspective)
+		}
+		options.Perspective = &boardshapes.PerspectiveOptions{}
+		for i, coordinate := range coordinates {
+			value, err := strconv.Atoi(strings.TrimSpace(coordinate))
+			if err != nil {
+				return options, fmt.Errorf("invalid perspective corners: %s", perspective)
+			}
+			if i%2 == 0 {
+				options.Perspective.Corners[i/2].X = value
+			} else {
+				options.Perspective.Corners[i/2].Y = value
+			}
+		}
+	}
+
+	if normalizeIllumination {
+		options.Illumination = &boardshapes.IlluminationOptions{
+			KernelSize:        illuminationKernelSize,
+			GlareCompensation: glareCompensation,
+		}
+	}
+	return options, nil
+}
+
+func getInputData(inputPath string) *boardshapes.BoardshapesData {
+	r := getInputReader(inputPath)
 
 	boardShapesData := deserializeBoardshapesData(r)
 	return boardShapesData
@@ -212,12 +538,29 @@ func deserializeBoardshapesData(r io.ReadSeeker) *boardshapes.BoardshapesData {
 			panic(err)
 		}
 	default:
-		log.Fatalf("unknown data format: %s\n", format)
+		panic(fmt.Errorf("unknown data format: %s", format))
 	}
 
 	return boardShapesData
 }
 
+// Builds shapes from an SVG or a GeoJSON file, telling them apart by their first character.
+func importInputData(inputPath string) *boardshapes.BoardshapesData {
+	r := getInputReader(inputPath)
+
+	var boardShapesData *boardshapes.BoardshapesData
+	var err error
+	if detectDataFormat(r) == "json" {
+		boardShapesData, err = serialization.GeoJsonDeserialize(r)
+	} else {
+		boardShapesData, err = serialization.SvgDeserialize(r, nil)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return boardShapesData
+}
+
 // todo: this should probably be in the serialization package.
 func detectDataFormat(r io.ReadSeeker) string {
 	buf := make([]byte, 1)
@@ -243,7 +586,7 @@ func decodeImageFromFile(r io.Reader) image.Image {
 	return img
 }
 
-func encodeImageToWriter(w io.Writer, img image.Image) {
+func encodeImageToWriter(w io.Writer, img image.Image, outputPath string) {
 	ext := strings.ToLower(filepath.Ext(outputPath))
 	var err error
 	switch ext {
@@ -295,8 +638,38 @@ func resize(img image.Image) image.Image {
 	return img
 }
 
-func outputSimplifiedImageToWriter(w io.Writer, img image.Image) {
-	simplifiedImage := boardshapes.SimplifyImage(img, boardshapes.ShapeCreationOptions{EpsilonRDP: optimizeShapeEpsilon})
+func outputSimplifiedImageToWriter(w io.Writer, img image.Image, outputPath string) {
+	// prepare the image like generating shapes does, so the preview matches what they're created from,
+	// but keep the size the resize flag gave it
+	opts := shapeCreationOptions
+	opts.NoResize = true
+	prepared := boardshapes.PrepareImageWithFallback(img, opts)
+	simplifiedImage := boardshapes.SimplifyImage(prepared.Image, opts)
+
+	encodeImageToWriter(w, simplifiedImage, outputPath)
+}
+
+func outputRenderedImageToWriter(w io.Writer, data *boardshapes.BoardshapesData, outputPath string) {
+	img := boardshapes.Render(data, boardshapes.RenderOptions{
+		Background: color.White,
+		Outlines:   annotateRender,
+		Vertices:   annotateRender,
+		Numbers:    annotateRender,
+		Legend:     annotateRender,
+	})
+
+	encodeImageToWriter(w, img, outputPath)
+}
+
+func outputDebugImageToWriter(w io.Writer, img image.Image, outputPath string) {
+	layout := boardshapes.DEBUG_LAYOUT_SIDE_BY_SIDE
+	if debugOverlayOnly {
+		layout = boardshapes.DEBUG_LAYOUT_OVERLAY
+	}
+	debugImage, err := boardshapes.DrawDebugImage(img, shapeCreationOptions, layout)
+	if err != nil {
+		panic(err)
+	}
 
-	encodeImageToWriter(w, simplifiedImage)
+	encodeImageToWriter(w, debugImage, outputPath)
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/boardshapes/boardshapes/server"
+)
+
+func serve() {
+	options := server.DefaultOptions
+	s := &http.Server{
+		Addr:              serveAddress,
+		Handler:           server.NewHandler(&options),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       options.Timeout,
+		// leave time to write the response after the handler's own timeout
+		WriteTimeout: options.Timeout + 10*time.Second,
+	}
+
+	log.Printf("listening on %s\n", serveAddress)
+	log.Fatalln(s.ListenAndServe())
+}
@@ -0,0 +1,131 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+type DebugLayout int
+
+const (
+	// The prepared image, the simplified image and the overlay, next to each other.
+	DEBUG_LAYOUT_SIDE_BY_SIDE DebugLayout = iota
+	// Only the overlay, drawn on top of the prepared image.
+	DEBUG_LAYOUT_OVERLAY
+)
+
+const DEBUG_PANEL_GAP = 8
+
+var (
+	DebugBoundaryColor = Cyan
+	DebugRawPathColor  = color.NRGBA{R: 255, G: 128, B: 0, A: 255}
+	DebugPathColor     = DefaultOutlineColor
+	DebugRejectedColor = Red
+)
+
+// Creates shapes from the image, then draws each stage of doing so: the prepared image, the simplified image,
+// and an overlay on the prepared image of the boundaries between regions, the outlines traced from them,
+// and the optimized paths of the shapes. Regions that didn't become shapes have their bounds outlined.
+// Everything is drawn at the size of the prepared image, which is the space the shapes are in.
+// Returns an error if the image couldn't be prepared, like [CreateShapesWithDiagnostics].
+func DrawDebugImage(img image.Image, opts ShapeCreationOptions, layout DebugLayout) (*image.NRGBA, error) {
+	result, err := CreateShapesWithDiagnostics(img, opts)
+	if err != nil {
+		return nil, err
+	}
+	prepared := result.Prepared.Image
+	simplified := SimplifyImage(prepared, opts)
+
+	key := []legendEntry{
+		{DebugBoundaryColor, "region boundary"},
+		{DebugRawPathColor, "traced outline"},
+		{DebugPathColor, "optimized path"},
+		{DebugRejectedColor, "region without a shape"},
+	}
+
+	panelBounds := image.Rect(0, 0, prepared.Bounds().Dx(), prepared.Bounds().Dy())
+
+	// the key goes below the image
+	overlayBounds := panelBounds
+	overlayBounds.Max.Y += len(key)*RENDER_LEGEND_ROW_HEIGHT + 4
+	for _, entry := range key {
+		overlayBounds.Max.X = max(overlayBounds.Max.X, 24+font.MeasureString(basicfont.Face7x13, entry.label).Ceil())
+	}
+	overlay := image.NewNRGBA(overlayBounds)
+	draw.Draw(overlay, overlayBounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(overlay, panelBounds, prepared, prepared.Bounds().Min, draw.Src)
+	// fade the image so the lines stand out
+	draw.Draw(overlay, panelBounds, image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: 160}), image.Point{}, draw.Over)
+	drawRegionBoundaries(overlay, simplified)
+
+	open := make(map[int]bool)
+	for _, shape := range result.Data.Shapes {
+		open[shape.Number] = shape.Open
+	}
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Err != nil {
+			drawRectangle(overlay, diagnostic.Bounds, DebugRejectedColor)
+			continue
+		}
+		corner := ShapeData{CornerX: diagnostic.Bounds.Min.X, CornerY: diagnostic.Bounds.Min.Y}
+		strokePaths(overlay, corner, append([]Path{diagnostic.RawPath}, diagnostic.RawHoles...), !open[diagnostic.Index], 1, DebugRawPathColor)
+	}
+	for _, shape := range result.Data.Shapes {
+		paths := append([]Path{shape.Path}, shape.Holes...)
+		strokePaths(overlay, shape, paths, !shape.Open, RENDER_OUTLINE_WIDTH, DebugPathColor)
+		drawVertexMarkers(overlay, shape, paths, DebugPathColor)
+	}
+
+	for i, entry := range key {
+		drawLegendRow(overlay, entry.color, entry.label, 4, panelBounds.Max.Y+4+i*RENDER_LEGEND_ROW_HEIGHT)
+	}
+
+	if layout == DEBUG_LAYOUT_OVERLAY {
+		return overlay, nil
+	}
+
+	panels := []struct {
+		img   image.Image
+		label string
+	}{
+		{prepared, "prepared"},
+		{simplified, "simplified"},
+		{overlay, "shapes"},
+	}
+	width := (len(panels)-1)*(panelBounds.Dx()+DEBUG_PANEL_GAP) + overlayBounds.Dx()
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, overlayBounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	for i, panel := range panels {
+		offset := image.Pt(i*(panelBounds.Dx()+DEBUG_PANEL_GAP), 0)
+		draw.Draw(canvas, panel.img.Bounds().Sub(panel.img.Bounds().Min).Add(offset), panel.img, panel.img.Bounds().Min, draw.Src)
+		drawLabel(canvas, panel.label, offset.X+4, 4)
+	}
+	return canvas, nil
+}
+
+// Marks every pixel of the simplified image that's next to a pixel of a different color.
+func drawRegionBoundaries(canvas *image.NRGBA, simplified image.Image) {
+	bounds := simplified.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := simplified.At(x, y)
+			if (x+1 < bounds.Max.X && !ColorRegionEquivalence(c, simplified.At(x+1, y))) ||
+				(y+1 < bounds.Max.Y && !ColorRegionEquivalence(c, simplified.At(x, y+1))) {
+				canvas.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, DebugBoundaryColor)
+			}
+		}
+	}
+}
+
+// Draws a one pixel wide outline just inside the rectangle.
+func drawRectangle(canvas draw.Image, r image.Rectangle, c color.Color) {
+	src := image.NewUniform(c)
+	draw.Draw(canvas, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), src, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+}
@@ -0,0 +1,57 @@
+package boardshapes
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestDrawDebugImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 80))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	// a square big enough to become a shape, and a speck too small to
+	draw.Draw(img, image.Rect(20, 20, 60, 60), image.Black, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(80, 70, 83, 73), image.Black, image.Point{}, draw.Src)
+
+	overlay, err := DrawDebugImage(img, ShapeCreationOptions{}, DEBUG_LAYOUT_OVERLAY)
+	if err != nil {
+		t.Fatalf("DrawDebugImage() error = %v", err)
+	}
+	// with the key below it
+	if bounds := overlay.Bounds(); bounds.Dx() < 100 || bounds.Dy() <= 80 {
+		t.Errorf("DrawDebugImage() overlay bounds = %v, want more than the image's %v", bounds, img.Bounds())
+	}
+	if c := overlay.NRGBAAt(40, 59); c != DebugBoundaryColor && c != DebugRawPathColor && c != DebugPathColor {
+		t.Errorf("DrawDebugImage() color on the edge of the square = %v, want a line", c)
+	}
+	if c := overlay.NRGBAAt(80, 70); c != DebugRejectedColor {
+		t.Errorf("DrawDebugImage() color on the corner of the speck = %v, want %v", c, DebugRejectedColor)
+	}
+
+	sideBySide, err := DrawDebugImage(img, ShapeCreationOptions{}, DEBUG_LAYOUT_SIDE_BY_SIDE)
+	if err != nil {
+		t.Fatalf("DrawDebugImage() error = %v", err)
+	}
+	if want := 2*(100+DEBUG_PANEL_GAP) + overlay.Bounds().Dx(); sideBySide.Bounds().Dx() != want {
+		t.Errorf("DrawDebugImage() side by side width = %d, want %d", sideBySide.Bounds().Dx(), want)
+	}
+}
+
+func TestCreateShapesWithDiagnostics_RawPath(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 80))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 20, 60, 60), image.Black, image.Point{}, draw.Src)
+
+	result, err := CreateShapesWithDiagnostics(img, ShapeCreationOptions{})
+	if err != nil {
+		t.Fatalf("CreateShapesWithDiagnostics() error = %v", err)
+	}
+	if len(result.Data.Shapes) != 1 || len(result.Diagnostics) != 1 {
+		t.Fatalf("CreateShapesWithDiagnostics() = %d shapes and %d diagnostics, want 1 of each",
+			len(result.Data.Shapes), len(result.Diagnostics))
+	}
+	raw, optimized := result.Diagnostics[0].RawPath, result.Data.Shapes[0].Path
+	if len(raw) <= len(optimized) {
+		t.Errorf("RawPath has %d vertices, want more than the %d of the optimized path", len(raw), len(optimized))
+	}
+}
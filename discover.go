@@ -0,0 +1,205 @@
+package boardshapes
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Pixels closer than this (in CIELAB) to the board's color are treated as part of the board.
+const MINIMUM_INK_DISTANCE = 25.0
+
+// Roughly the most pixels that are looked at when discovering colors.
+const MAXIMUM_DISCOVERY_SAMPLES = 200_000
+
+const MAXIMUM_KMEANS_ITERATIONS = 20
+
+// Colors used to name discovered colors.
+var knownColors = []PaletteEntry{
+	{"Black", color.NRGBA{0, 0, 0, 255}},
+	{"Grey", color.NRGBA{128, 128, 128, 255}},
+	{"White", color.NRGBA{255, 255, 255, 255}},
+	{"Red", color.NRGBA{220, 30, 30, 255}},
+	{"Orange", color.NRGBA{255, 140, 0, 255}},
+	{"Yellow", color.NRGBA{255, 220, 0, 255}},
+	{"Green", color.NRGBA{30, 160, 50, 255}},
+	{"Cyan", color.NRGBA{0, 190, 220, 255}},
+	{"Blue", color.NRGBA{30, 70, 200, 255}},
+	{"Purple", color.NRGBA{120, 50, 170, 255}},
+	{"Pink", color.NRGBA{230, 60, 160, 255}},
+	{"Brown", color.NRGBA{130, 80, 30, 255}},
+}
+
+// A palette discovered from an image by [DiscoverPalette].
+type DiscoveredPalette struct {
+	// White, followed by the discovered ink colors.
+	Palette
+	// The color of the board (the median color of the image), which is simplified to white.
+	Background color.NRGBA
+}
+
+// Creates a classifier that simplifies pixels close to the board to white, and the rest to the nearest ink color.
+func (dp DiscoveredPalette) Classifier() *LabClassifier {
+	references := dp.Colors()
+	for i, c := range references {
+		if c == White {
+			references[i] = dp.Background
+		}
+	}
+	return newLabClassifierWithReferences(dp.Colors(), references, dp.Tolerance)
+}
+
+type discoverySample struct {
+	lab  [3]float64
+	nrgb color.NRGBA
+}
+
+// Finds (up to) the given number of dominant ink colors in a photo of a board by k-means clustering
+// every pixel that's noticeably different from the board in the CIELAB color space.
+// Each ink color is named after the closest of a set of common color names.
+func DiscoverPalette(img image.Image, inks int) DiscoveredPalette {
+	bd := img.Bounds()
+	step := max(1, int(math.Ceil(math.Sqrt(float64(bd.Dx()*bd.Dy())/MAXIMUM_DISCOVERY_SAMPLES))))
+
+	samples := make([]discoverySample, 0, (bd.Dx()/step+1)*(bd.Dy()/step+1))
+	for y := bd.Min.Y; y < bd.Max.Y; y += step {
+		for x := bd.Min.X; x < bd.Max.X; x += step {
+			c := GetNRGBA(img.At(x, y))
+			if int(c.A) < DefaultColorClassifier.MinAlpha {
+				continue
+			}
+			samples = append(samples, discoverySample{ToLab(c), c})
+		}
+	}
+
+	discovered := DiscoveredPalette{
+		Palette:    Palette{Entries: []PaletteEntry{{"White", White}}},
+		Background: White,
+	}
+	if len(samples) == 0 {
+		return discovered
+	}
+
+	// the board takes up most of the image, so its color is the median
+	background := [3]float64{}
+	channel := make([]float64, len(samples))
+	for i := range background {
+		for j, s := range samples {
+			channel[j] = s.lab[i]
+		}
+		slices.Sort(channel)
+		background[i] = channel[len(channel)/2]
+	}
+	discovered.Background = slices.MinFunc(samples, func(a, b discoverySample) int {
+		return cmp.Compare(LabDistance(a.lab, background), LabDistance(b.lab, background))
+	}).nrgb
+
+	inkSamples := slices.DeleteFunc(samples, func(s discoverySample) bool {
+		return LabDistance(s.lab, background) < MINIMUM_INK_DISTANCE
+	})
+	if len(inkSamples) == 0 || inks <= 0 {
+		return discovered
+	}
+
+	usedNames := map[string]int{"White": 1}
+	for _, centroid := range kMeans(inkSamples, inks) {
+		name := slices.MinFunc(knownColors, func(a, b PaletteEntry) int {
+			return cmp.Compare(LabDistance(centroid.lab, ToLab(a.Color)), LabDistance(centroid.lab, ToLab(b.Color)))
+		}).Name
+		usedNames[name]++
+		if n := usedNames[name]; n > 1 {
+			name += " " + strconv.Itoa(n)
+		}
+		discovered.Entries = append(discovered.Entries, PaletteEntry{name, centroid.nrgb})
+	}
+
+	return discovered
+}
+
+// Clusters the samples into k clusters, returning the (non-empty) clusters' average colors, largest cluster first.
+func kMeans(samples []discoverySample, k int) []discoverySample {
+	// deterministic farthest-point initialization
+	centroids := []discoverySample{samples[0]}
+	for len(centroids) < k {
+		farthest, farthestDistance := -1, 0.0
+		for i, s := range samples {
+			d := math.Inf(1)
+			for _, c := range centroids {
+				d = min(d, LabDistance(s.lab, c.lab))
+			}
+			if d > farthestDistance {
+				farthest, farthestDistance = i, d
+			}
+		}
+		if farthest == -1 {
+			// fewer distinct colors than clusters
+			break
+		}
+		centroids = append(centroids, samples[farthest])
+	}
+
+	assignments := make([]int, len(samples))
+	counts := make([]int, len(centroids))
+	for iteration := range MAXIMUM_KMEANS_ITERATIONS {
+		changed := false
+		for i, s := range samples {
+			nearest, nearestDistance := 0, math.Inf(1)
+			for j, c := range centroids {
+				if d := LabDistance(s.lab, c.lab); d < nearestDistance {
+					nearest, nearestDistance = j, d
+				}
+			}
+			if assignments[i] != nearest || iteration == 0 {
+				assignments[i] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		labSums := make([][3]float64, len(centroids))
+		rgbSums := make([][3]int, len(centroids))
+		clear(counts)
+		for i, s := range samples {
+			j := assignments[i]
+			counts[j]++
+			for c := range 3 {
+				labSums[j][c] += s.lab[c]
+			}
+			rgbSums[j][0] += int(s.nrgb.R)
+			rgbSums[j][1] += int(s.nrgb.G)
+			rgbSums[j][2] += int(s.nrgb.B)
+		}
+		for j := range centroids {
+			if counts[j] == 0 {
+				continue
+			}
+			n := float64(counts[j])
+			centroids[j].lab = [3]float64{labSums[j][0] / n, labSums[j][1] / n, labSums[j][2] / n}
+			centroids[j].nrgb = color.NRGBA{
+				uint8(rgbSums[j][0] / counts[j]),
+				uint8(rgbSums[j][1] / counts[j]),
+				uint8(rgbSums[j][2] / counts[j]),
+				255,
+			}
+		}
+	}
+
+	order := make([]int, 0, len(centroids))
+	for j := range centroids {
+		if counts[j] > 0 {
+			order = append(order, j)
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return counts[b] - counts[a] })
+
+	result := make([]discoverySample, len(order))
+	for i, j := range order {
+		result[i] = centroids[j]
+	}
+	return result
+}
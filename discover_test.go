@@ -0,0 +1,40 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestDiscoverPalette(t *testing.T) {
+	// a slightly grey board with an orange and a cyan stroke
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{205, 208, 200, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 20, 120, 60), image.NewUniform(color.NRGBA{240, 130, 20, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(150, 100, 280, 130), image.NewUniform(color.NRGBA{20, 180, 210, 255}), image.Point{}, draw.Src)
+
+	discovered := DiscoverPalette(img, 2)
+
+	if discovered.Background != (color.NRGBA{205, 208, 200, 255}) {
+		t.Errorf("DiscoverPalette() background = %v", discovered.Background)
+	}
+
+	names := make([]string, len(discovered.Entries))
+	for i, entry := range discovered.Entries {
+		names[i] = entry.Name
+	}
+	if len(names) != 3 || names[0] != "White" || names[1] != "Orange" || names[2] != "Cyan" {
+		t.Fatalf("DiscoverPalette() names = %v, want [White Orange Cyan]", names)
+	}
+
+	data := CreateShapes(img, ShapeCreationOptions{DiscoverColors: 2})
+	if len(data.Shapes) != 2 {
+		t.Fatalf("CreateShapes() created %d shapes, want 2", len(data.Shapes))
+	}
+	for _, shape := range data.Shapes {
+		if shape.ColorName != "Cyan" && shape.ColorName != "Orange" {
+			t.Errorf("CreateShapes() shape color = %s, want Cyan or Orange", shape.ColorName)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+)
+
+const DEFAULT_ILLUMINATION_KERNEL_SIZE = 64
+
+// The percentile of each channel in an area that is taken as the brightness of the board in that area.
+// It's not the brightest pixel, so that specks of glare don't count as the board.
+const BOARD_BRIGHTNESS_PERCENTILE = 0.9
+
+type IlluminationOptions struct {
+	// The size (in pixels) of the area around each pixel that is used to estimate the board's brightness.
+	// It should be a fair bit larger than the thickest stroke on the board.
+	// Uses [DEFAULT_ILLUMINATION_KERNEL_SIZE] if 0 or less.
+	KernelSize int
+	// How much of the brightness above the board's typical brightness is treated as glare and removed (0 to 1).
+	// Glare washes out strokes instead of just brightening them, so dividing it out alone isn't enough.
+	GlareCompensation float64
+}
+
+// Flattens uneven lighting (such as shadows and glare) and white-balances a photo of a board,
+// by estimating what the board looks like without anything drawn on it and dividing it out of the image.
+func NormalizeIllumination(img image.Image, opts IlluminationOptions) image.Image {
+	kernelSize := opts.KernelSize
+	if kernelSize <= 0 {
+		kernelSize = DEFAULT_ILLUMINATION_KERNEL_SIZE
+	}
+
+	bd := img.Bounds()
+	width, height := bd.Dx(), bd.Dy()
+	result := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if width == 0 || height == 0 {
+		return result
+	}
+
+	for y := range height {
+		for x := range width {
+			result.SetNRGBA(x, y, GetNRGBA(img.At(bd.Min.X+x, bd.Min.Y+y)))
+		}
+	}
+
+	background := estimateBackground(result, max(1, kernelSize/4))
+
+	// the board's typical brightness, used to tell glare apart from the board
+	typical := 0.0
+	if opts.GlareCompensation > 0 {
+		brightness := make([]float64, len(background.cells))
+		for i, c := range background.cells {
+			brightness[i] = (c[0] + c[1] + c[2]) / 3
+		}
+		slices.Sort(brightness)
+		typical = brightness[len(brightness)/2]
+	}
+
+	for y := range height {
+		for x := range width {
+			bg := background.at(x, y)
+			glare := 0.0
+			if opts.GlareCompensation > 0 {
+				glare = max(0, (bg[0]+bg[1]+bg[2])/3-typical) * opts.GlareCompensation
+			}
+
+			c := result.NRGBAAt(x, y)
+			channels := [3]uint8{c.R, c.G, c.B}
+			for i, v := range channels {
+				board := max(1, bg[i]-glare)
+				channels[i] = uint8(math.Round(min(255, max(0, float64(v)-glare)*255/board)))
+			}
+			result.SetNRGBA(x, y, color.NRGBA{channels[0], channels[1], channels[2], c.A})
+		}
+	}
+
+	return result
+}
+
+// A low resolution estimate of what the board looks like, with one color per cell.
+type backgroundEstimate struct {
+	cells                   [][3]float64
+	columns, rows, cellSize int
+}
+
+func estimateBackground(img *image.NRGBA, cellSize int) backgroundEstimate {
+	bd := img.Bounds()
+	columns := (bd.Dx() + cellSize - 1) / cellSize
+	rows := (bd.Dy() + cellSize - 1) / cellSize
+	estimate := backgroundEstimate{make([][3]float64, columns*rows), columns, rows, cellSize}
+
+	// take a bright percentile of each cell, which skips over most strokes
+	var histograms [3][256]int
+	for row := range rows {
+		for column := range columns {
+			clear(histograms[:])
+			cell := image.Rect(column*cellSize, row*cellSize, (column+1)*cellSize, (row+1)*cellSize).Intersect(bd)
+			for y := cell.Min.Y; y < cell.Max.Y; y++ {
+				for x := cell.Min.X; x < cell.Max.X; x++ {
+					c := img.NRGBAAt(x, y)
+					histograms[0][c.R]++
+					histograms[1][c.G]++
+					histograms[2][c.B]++
+				}
+			}
+			target := int(float64(cell.Dx()*cell.Dy()) * BOARD_BRIGHTNESS_PERCENTILE)
+			for i := range 3 {
+				count := 0
+				for v, n := range histograms[i] {
+					count += n
+					if count > target {
+						estimate.cells[row*columns+column][i] = float64(v)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// thick strokes can fill a whole cell, so take the brightest neighbor (closing the gaps they leave)
+	// and then smooth it all out
+	estimate.filter(func(values [][3]float64) (result [3]float64) {
+		for _, v := range values {
+			for i := range 3 {
+				result[i] = max(result[i], v[i])
+			}
+		}
+		return
+	})
+	for range 2 {
+		estimate.filter(func(values [][3]float64) (result [3]float64) {
+			for _, v := range values {
+				for i := range 3 {
+					result[i] += v[i] / float64(len(values))
+				}
+			}
+			return
+		})
+	}
+
+	return estimate
+}
+
+// Replaces each cell with the result of the function on it and its neighbors.
+func (be *backgroundEstimate) filter(function func(values [][3]float64) [3]float64) {
+	filtered := make([][3]float64, len(be.cells))
+	values := make([][3]float64, 0, 9)
+	for row := range be.rows {
+		for column := range be.columns {
+			values = values[:0]
+			for y := max(0, row-1); y <= min(be.rows-1, row+1); y++ {
+				for x := max(0, column-1); x <= min(be.columns-1, column+1); x++ {
+					values = append(values, be.cells[y*be.columns+x])
+				}
+			}
+			filtered[row*be.columns+column] = function(values)
+		}
+	}
+	be.cells = filtered
+}
+
+// Returns the estimated board color at the pixel, interpolated between the centers of the cells around it.
+func (be *backgroundEstimate) at(x, y int) (result [3]float64) {
+	fx := min(max(0, (float64(x)+0.5)/float64(be.cellSize)-0.5), float64(be.columns-1))
+	fy := min(max(0, (float64(y)+0.5)/float64(be.cellSize)-0.5), float64(be.rows-1))
+	x0, y0 := int(fx), int(fy)
+	x1, y1 := min(x0+1, be.columns-1), min(y0+1, be.rows-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	c00, c10 := be.cells[y0*be.columns+x0], be.cells[y0*be.columns+x1]
+	c01, c11 := be.cells[y1*be.columns+x0], be.cells[y1*be.columns+x1]
+	for i := range 3 {
+		top := c00[i]*(1-tx) + c10[i]*tx
+		bottom := c01[i]*(1-tx) + c11[i]*tx
+		result[i] = top*(1-ty) + bottom*ty
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNormalizeIllumination(t *testing.T) {
+	// a board that gets darker towards the right, with a slight blue tint and a dark stroke down the middle
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := range 200 {
+		for x := range 400 {
+			brightness := 240 - float64(x)*150/400
+			reflectance := 1.0
+			if y >= 90 && y < 100 {
+				reflectance = 0.15
+			}
+			v := brightness * reflectance
+			img.Set(x, y, color.NRGBA{uint8(v * 0.95), uint8(v * 0.97), uint8(v), 255})
+		}
+	}
+
+	if c := SimplifyImage(img, ShapeCreationOptions{}).At(390, 20); c != Black {
+		t.Fatalf("shadowed board should be black before normalizing, got %v", c)
+	}
+
+	simplified := SimplifyImage(NormalizeIllumination(img, IlluminationOptions{}), ShapeCreationOptions{})
+	for _, x := range []int{10, 200, 390} {
+		if c := simplified.At(x, 20); c != White {
+			t.Errorf("normalized board at x = %d = %v, want %v", x, c, White)
+		}
+		if c := simplified.At(x, 95); c != Black {
+			t.Errorf("normalized stroke at x = %d = %v, want %v", x, c, Black)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package boardshapes
+
+import (
+	"maps"
+	"strconv"
+	"time"
+)
+
+// Information about where shapes came from and how they were created.
+type Metadata struct {
+	// Size of the image the shapes were created from, which is the space their positions are in.
+	// If the image was resized or its perspective corrected, this is the size after doing so.
+	ImageWidth, ImageHeight int
+	// Size of the original image, before it was resized or its perspective corrected. Zero if unknown.
+	SourceWidth, SourceHeight int
+	// How much the original image was scaled by before shapes were created from it, or 1 if it wasn't.
+	// If the perspective of the image was corrected, this is how much the corrected image was scaled by.
+	Scale float64
+	// The options the shapes were created with, from [ShapeCreationOptions.Summary].
+	// If the perspective of the image couldn't be corrected and it was used as-is, "perspective" is "failed".
+	Options map[string]string
+	// When the shapes were created. Zero if unknown.
+	CreatedAt time.Time
+	// Free-form labels, for anything else worth keeping with the data.
+	Tags map[string]string
+}
+
+// Returns the options as names and values, leaving out any that are unset.
+// Classifiers and palettes aren't included, since the palette is already kept with the data.
+func (opts ShapeCreationOptions) Summary() map[string]string {
+	summary := make(map[string]string)
+	setBool := func(name string, value bool) {
+		if value {
+			summary[name] = "true"
+		}
+	}
+	setFloat := func(name string, value float64) {
+		if value != 0 {
+			summary[name] = strconv.FormatFloat(value, 'g', -1, 64)
+		}
+	}
+
+	setBool("noColorSeparation", opts.NoColorSeparation)
+	setBool("allowWhite", opts.AllowWhite)
+	setBool("preserveColor", opts.PreserveColor)
+	setBool("keepSmallRegions", opts.KeepSmallRegions)
+	setBool("noResize", opts.NoResize)
+	if opts.MinRegionSize > 0 {
+		summary["minRegionSize"] = strconv.Itoa(opts.MinRegionSize)
+	}
+	setFloat("epsilonRDP", opts.EpsilonRDP)
+	if opts.DiscoverColors > 0 {
+		summary["discoverColors"] = strconv.Itoa(opts.DiscoverColors)
+	}
+	setBool("perspective", opts.Perspective != nil)
+	setBool("illumination", opts.Illumination != nil)
+	setBool("extractCenterlines", opts.ExtractCenterlines)
+	setBool("recognizePrimitives", opts.RecognizePrimitives)
+	setFloat("curveTolerance", opts.CurveTolerance)
+	return summary
+}
+
+func (m *Metadata) Equal(other *Metadata) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	return m.ImageWidth == other.ImageWidth &&
+		m.ImageHeight == other.ImageHeight &&
+		m.SourceWidth == other.SourceWidth &&
+		m.SourceHeight == other.SourceHeight &&
+		m.Scale == other.Scale &&
+		maps.Equal(m.Options, other.Options) &&
+		m.CreatedAt.Equal(other.CreatedAt) &&
+		maps.Equal(m.Tags, other.Tags)
+}
+
+// Records that the image the shapes were created from had already been resized from an image of the given size,
+// so the metadata's source size and scale, and the transform, are of that image instead.
+func (data *BoardshapesData) SetSourceSize(width, height int) {
+	metadata := data.Metadata
+	if metadata == nil || metadata.SourceWidth <= 0 || metadata.SourceHeight <= 0 {
+		return
+	}
+	sx := float64(width) / float64(metadata.SourceWidth)
+	sy := float64(height) / float64(metadata.SourceHeight)
+	metadata.Scale /= sx
+	if data.Transform != nil {
+		transform := ScaleHomography(sx, sy).Multiply(*data.Transform)
+		data.Transform = &transform
+	}
+	metadata.SourceWidth, metadata.SourceHeight = width, height
+}
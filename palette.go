@@ -0,0 +1,86 @@
+package boardshapes
+
+import (
+	"image/color"
+	"math"
+)
+
+type PaletteEntry struct {
+	Name  string
+	Color color.NRGBA
+}
+
+// A list of named colors that shapes can be classified as.
+type Palette struct {
+	Entries []PaletteEntry
+	// The furthest (CIELAB) distance a color can be from an entry and still match it.
+	// Pixels that don't match any entry become white, and shapes that don't match any entry have no color name.
+	// If zero or less, pixels always become the nearest entry, but names are only given to exact matches.
+	Tolerance float64
+}
+
+// The palette used when [ShapeCreationOptions.Palette] is not set, matching [DefaultColorClassifier].
+var DefaultPalette = Palette{
+	Entries: []PaletteEntry{
+		{"Red", Red},
+		{"Green", Green},
+		{"Blue", Blue},
+		{"Black", Black},
+		{"White", White},
+	},
+}
+
+// [DefaultPalette] along with cyan, magenta and yellow.
+var ExtendedPalette = Palette{
+	Entries: []PaletteEntry{
+		{"Red", Red},
+		{"Green", Green},
+		{"Blue", Blue},
+		{"Black", Black},
+		{"White", White},
+		{"Cyan", Cyan},
+		{"Magenta", Magenta},
+		{"Yellow", Yellow},
+	},
+}
+
+func (p Palette) Colors() []color.NRGBA {
+	colors := make([]color.NRGBA, len(p.Entries))
+	for i, entry := range p.Entries {
+		colors[i] = entry.Color
+	}
+	return colors
+}
+
+// Returns the entry matching the color, preferring exact matches over the nearest entry within the tolerance.
+func (p Palette) Match(c color.Color) (entry PaletteEntry, ok bool) {
+	nrgba := GetNRGBA(c)
+	for _, entry := range p.Entries {
+		if entry.Color == nrgba {
+			return entry, true
+		}
+	}
+	if p.Tolerance <= 0 {
+		return PaletteEntry{}, false
+	}
+
+	lab := ToLab(nrgba)
+	nearestDistance := math.Inf(1)
+	for _, e := range p.Entries {
+		if d := LabDistance(lab, ToLab(e.Color)); d < nearestDistance {
+			entry, nearestDistance = e, d
+		}
+	}
+	return entry, nearestDistance <= p.Tolerance
+}
+
+// Returns the name of the entry matching the color, or an empty string if none match.
+func (p Palette) Name(c color.Color) string {
+	entry, _ := p.Match(c)
+	return entry.Name
+}
+
+// Creates a classifier that simplifies pixels to the nearest color in the palette.
+func (p Palette) Classifier() *LabClassifier {
+	return NewLabClassifier(p.Colors(), p.Tolerance)
+}
@@ -0,0 +1,63 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var testPalette = Palette{
+	Entries: []PaletteEntry{
+		{"White", White},
+		{"lava orange", color.NRGBA{255, 120, 0, 255}},
+		{"spawn purple", color.NRGBA{130, 40, 200, 255}},
+	},
+	Tolerance: 40,
+}
+
+func TestPalette_Match(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        color.Color
+		wantName string
+		wantOk   bool
+	}{
+		{"exact", color.NRGBA{255, 120, 0, 255}, "lava orange", true},
+		{"near", color.NRGBA{240, 110, 20, 255}, "lava orange", true},
+		{"too far", color.NRGBA{0, 200, 0, 255}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := testPalette.Match(tt.c)
+			if ok != tt.wantOk || (ok && entry.Name != tt.wantName) {
+				t.Errorf("Palette.Match(%v) = %v, %t, want %s, %t", tt.c, entry, ok, tt.wantName, tt.wantOk)
+			}
+		})
+	}
+
+	if name := DefaultPalette.Name(Cyan); name != "" {
+		t.Errorf("Palette.Name(%v) = %s, want no name without a tolerance", Cyan, name)
+	}
+}
+
+func TestCreateShapesWithPalette(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 20, 70, 70), image.NewUniform(color.NRGBA{235, 115, 30, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(120, 120, 170, 170), image.NewUniform(color.NRGBA{120, 50, 190, 255}), image.Point{}, draw.Src)
+
+	data := CreateShapes(img, ShapeCreationOptions{Palette: &testPalette})
+
+	if len(data.Palette.Entries) != len(testPalette.Entries) {
+		t.Errorf("CreateShapes() palette has %d entries, want %d", len(data.Palette.Entries), len(testPalette.Entries))
+	}
+
+	names := make(map[string]bool)
+	for _, shape := range data.Shapes {
+		names[shape.ColorName] = true
+	}
+	if len(data.Shapes) != 2 || !names["lava orange"] || !names["spawn purple"] {
+		t.Errorf("CreateShapes() shape colors = %v, want lava orange and spawn purple", names)
+	}
+}
@@ -0,0 +1,265 @@
+package boardshapes
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+var ErrBoardNotFound = errors.New("perspective: could not find the corners of the board")
+var ErrDegenerateCorners = errors.New("perspective: corners do not form a quadrilateral")
+
+// The largest width of the image that board corners are detected on. Larger images are scaled down first.
+const CORNER_DETECTION_WIDTH = 400
+
+// The smallest fraction of the image that the board has to take up for its corners to be detected.
+const MINIMUM_BOARD_FRACTION = 0.2
+
+// A 3x3 projective transform (in row-major order) mapping points from one plane to another.
+type Homography [9]float64
+
+var IdentityHomography = Homography{1, 0, 0, 0, 1, 0, 0, 0, 1}
+
+func ScaleHomography(sx, sy float64) Homography {
+	return Homography{sx, 0, 0, 0, sy, 0, 0, 0, 1}
+}
+
+// Transforms the point.
+func (h Homography) Apply(x, y float64) (float64, float64) {
+	w := h[6]*x + h[7]*y + h[8]
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
+
+// Returns the homography that applies other first, then h.
+func (h Homography) Multiply(other Homography) (result Homography) {
+	for row := range 3 {
+		for col := range 3 {
+			for i := range 3 {
+				result[row*3+col] += h[row*3+i] * other[i*3+col]
+			}
+		}
+	}
+	return result
+}
+
+// Returns the homography that undoes h, or false if h can't be undone.
+func (h Homography) Inverse() (Homography, bool) {
+	a, b, c, d, e, f, g, i, j := h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7], h[8]
+	det := a*(e*j-f*i) - b*(d*j-f*g) + c*(d*i-e*g)
+	if math.Abs(det) < 1e-12 {
+		return Homography{}, false
+	}
+	return Homography{
+		(e*j - f*i) / det, (c*i - b*j) / det, (b*f - c*e) / det,
+		(f*g - d*j) / det, (a*j - c*g) / det, (c*d - a*f) / det,
+		(d*i - e*g) / det, (b*g - a*i) / det, (a*e - b*d) / det,
+	}, true
+}
+
+// Finds the homography mapping each of the source points onto the destination point at the same index.
+func HomographyFromPoints(src, dst [4]image.Point) (Homography, error) {
+	// solve the 8 unknowns (the last one is always 1) with gaussian elimination
+	var m [8][9]float64
+	for i := range 4 {
+		x, y := float64(src[i].X), float64(src[i].Y)
+		u, v := float64(dst[i].X), float64(dst[i].Y)
+		m[i*2] = [9]float64{x, y, 1, 0, 0, 0, -x * u, -y * u, u}
+		m[i*2+1] = [9]float64{0, 0, 0, x, y, 1, -x * v, -y * v, v}
+	}
+
+	for col := range 8 {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-9 {
+			return Homography{}, ErrDegenerateCorners
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+
+		for row := range 8 {
+			if row == col {
+				continue
+			}
+			factor := m[row][col] / m[col][col]
+			for k := col; k < 9; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+		}
+	}
+
+	var h Homography
+	for i := range 8 {
+		h[i] = m[i][8] / m[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
+
+type PerspectiveOptions struct {
+	// The corners of the board in the photo, clockwise from the top-left.
+	// If they are all zero, they are found with [DetectBoardCorners].
+	Corners [4]image.Point
+}
+
+func (po PerspectiveOptions) correct(img image.Image) (image.Image, Homography, error) {
+	corners := po.Corners
+	if corners == [4]image.Point{} {
+		var err error
+		if corners, err = DetectBoardCorners(img); err != nil {
+			return nil, Homography{}, err
+		}
+	}
+	return CorrectPerspective(img, corners)
+}
+
+// Warps the board with the given corners (clockwise from the top-left) into a rectangle, so that it looks like it
+// was photographed head-on. Also returns the homography that maps points in the corrected image back to the photo.
+func CorrectPerspective(img image.Image, corners [4]image.Point) (corrected image.Image, transform Homography, err error) {
+	dist := func(a, b image.Point) float64 {
+		return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+	}
+	width := int(math.Round(max(dist(corners[0], corners[1]), dist(corners[3], corners[2]))))
+	height := int(math.Round(max(dist(corners[0], corners[3]), dist(corners[1], corners[2]))))
+	if width < 1 || height < 1 {
+		return nil, Homography{}, ErrDegenerateCorners
+	}
+
+	rect := [4]image.Point{{0, 0}, {width - 1, 0}, {width - 1, height - 1}, {0, height - 1}}
+	transform, err = HomographyFromPoints(rect, corners)
+	if err != nil {
+		return nil, Homography{}, err
+	}
+
+	bd := img.Bounds()
+	result := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			sx, sy := transform.Apply(float64(x), float64(y))
+			p := image.Pt(int(math.Round(sx)), int(math.Round(sy)))
+			if p.In(bd) {
+				result.Set(x, y, img.At(p.X, p.Y))
+			} else {
+				result.Set(x, y, White)
+			}
+		}
+	}
+
+	return result, transform, nil
+}
+
+// Finds the corners of the board in a photo, clockwise from the top-left,
+// assuming the board is the largest bright area in the photo.
+func DetectBoardCorners(img image.Image) ([4]image.Point, error) {
+	bd := img.Bounds()
+	small := img
+	scale := 1.0
+	if bd.Dx() > CORNER_DETECTION_WIDTH {
+		small = ResizeImageTo(img, CORNER_DETECTION_WIDTH, 0)
+		scale = float64(bd.Dx()) / float64(small.Bounds().Dx())
+	}
+	sbd := small.Bounds()
+	width, height := sbd.Dx(), sbd.Dy()
+	if width == 0 || height == 0 {
+		return [4]image.Point{}, ErrBoardNotFound
+	}
+
+	luminance := make([]uint8, width*height)
+	var histogram [256]int
+	for y := range height {
+		for x := range width {
+			l := color.GrayModel.Convert(small.At(sbd.Min.X+x, sbd.Min.Y+y)).(color.Gray).Y
+			luminance[y*width+x] = l
+			histogram[l]++
+		}
+	}
+	threshold := otsuThreshold(histogram, width*height)
+
+	// find the largest bright area
+	visited := make([]bool, width*height)
+	var largest []image.Point
+	for start := range luminance {
+		if visited[start] || luminance[start] <= threshold {
+			continue
+		}
+		area := make([]image.Point, 0)
+		toVisit := []int{start}
+		visited[start] = true
+		for len(toVisit) > 0 {
+			i := toVisit[len(toVisit)-1]
+			toVisit = toVisit[:len(toVisit)-1]
+			area = append(area, image.Pt(i%width, i/width))
+			forNonDiagonalAdjacents(uint16(i%width), uint16(i/width), width, height, func(x, y uint16) {
+				j := int(y)*width + int(x)
+				if !visited[j] && luminance[j] > threshold {
+					visited[j] = true
+					toVisit = append(toVisit, j)
+				}
+			})
+		}
+		if len(area) > len(largest) {
+			largest = area
+		}
+	}
+
+	if float64(len(largest)) < MINIMUM_BOARD_FRACTION*float64(width*height) {
+		return [4]image.Point{}, ErrBoardNotFound
+	}
+
+	// the corners are the points furthest along each diagonal
+	corners := [4]image.Point{largest[0], largest[0], largest[0], largest[0]}
+	for _, p := range largest {
+		if p.X+p.Y < corners[0].X+corners[0].Y {
+			corners[0] = p
+		}
+		if p.X-p.Y > corners[1].X-corners[1].Y {
+			corners[1] = p
+		}
+		if p.X+p.Y > corners[2].X+corners[2].Y {
+			corners[2] = p
+		}
+		if p.Y-p.X > corners[3].Y-corners[3].X {
+			corners[3] = p
+		}
+	}
+
+	for i, c := range corners {
+		corners[i] = image.Pt(
+			bd.Min.X+int(math.Round((float64(c.X)+0.5)*scale-0.5)),
+			bd.Min.Y+int(math.Round((float64(c.Y)+0.5)*scale-0.5)))
+	}
+	return corners, nil
+}
+
+// Finds the threshold that best separates the histogram into dark and bright pixels.
+func otsuThreshold(histogram [256]int, total int) uint8 {
+	sum := 0.0
+	for i, n := range histogram {
+		sum += float64(i * n)
+	}
+
+	var threshold uint8
+	sumDark, countDark, bestVariance := 0.0, 0, -1.0
+	for i, n := range histogram {
+		countDark += n
+		if countDark == 0 {
+			continue
+		}
+		countBright := total - countDark
+		if countBright == 0 {
+			break
+		}
+		sumDark += float64(i * n)
+		meanDark := sumDark / float64(countDark)
+		meanBright := (sum - sumDark) / float64(countBright)
+		variance := float64(countDark) * float64(countBright) * (meanDark - meanBright) * (meanDark - meanBright)
+		if variance > bestVariance {
+			bestVariance = variance
+			threshold = uint8(i)
+		}
+	}
+	return threshold
+}
@@ -0,0 +1,114 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// Fills the convex quadrilateral (clockwise on screen) with the color.
+func fillQuad(img *image.NRGBA, quad [4]image.Point, c color.Color) {
+	bd := img.Bounds()
+	for y := bd.Min.Y; y < bd.Max.Y; y++ {
+		for x := bd.Min.X; x < bd.Max.X; x++ {
+			inside := true
+			for i := range 4 {
+				a, b := quad[i], quad[(i+1)%4]
+				if (b.X-a.X)*(y-a.Y)-(b.Y-a.Y)*(x-a.X) < 0 {
+					inside = false
+				}
+			}
+			if inside {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+func TestHomographyFromPoints(t *testing.T) {
+	src := [4]image.Point{{0, 0}, {100, 0}, {100, 50}, {0, 50}}
+	dst := [4]image.Point{{10, 20}, {120, 5}, {130, 90}, {5, 70}}
+
+	h, err := HomographyFromPoints(src, dst)
+	if err != nil {
+		t.Fatalf("HomographyFromPoints() error = %v", err)
+	}
+	inverse, ok := h.Inverse()
+	if !ok {
+		t.Fatalf("Homography.Inverse() failed")
+	}
+
+	for i := range 4 {
+		x, y := h.Apply(float64(src[i].X), float64(src[i].Y))
+		if math.Abs(x-float64(dst[i].X)) > 1e-6 || math.Abs(y-float64(dst[i].Y)) > 1e-6 {
+			t.Errorf("Homography.Apply(%v) = (%f, %f), want %v", src[i], x, y, dst[i])
+		}
+		x, y = inverse.Apply(x, y)
+		if math.Abs(x-float64(src[i].X)) > 1e-6 || math.Abs(y-float64(src[i].Y)) > 1e-6 {
+			t.Errorf("inverse Homography.Apply() = (%f, %f), want %v", x, y, src[i])
+		}
+	}
+
+	if _, err := HomographyFromPoints(src, [4]image.Point{{0, 0}, {0, 0}, {0, 0}, {0, 0}}); err == nil {
+		t.Errorf("HomographyFromPoints() with degenerate points should fail")
+	}
+}
+
+func TestDetectBoardCorners(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 600))
+	fillQuad(img, [4]image.Point{{0, 0}, {800, 0}, {800, 600}, {0, 600}}, color.NRGBA{60, 50, 45, 255})
+	board := [4]image.Point{{120, 80}, {700, 40}, {760, 560}, {60, 500}}
+	fillQuad(img, board, color.NRGBA{230, 232, 228, 255})
+
+	corners, err := DetectBoardCorners(img)
+	if err != nil {
+		t.Fatalf("DetectBoardCorners() error = %v", err)
+	}
+	for i := range 4 {
+		if d := math.Hypot(float64(corners[i].X-board[i].X), float64(corners[i].Y-board[i].Y)); d > 6 {
+			t.Errorf("DetectBoardCorners() corner %d = %v, want %v", i, corners[i], board[i])
+		}
+	}
+
+	dark := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	if _, err := DetectBoardCorners(dark); err != ErrBoardNotFound {
+		t.Errorf("DetectBoardCorners() on an empty image error = %v, want %v", err, ErrBoardNotFound)
+	}
+}
+
+func TestCreateShapesWithPerspective(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	fillQuad(img, [4]image.Point{{0, 0}, {400, 0}, {400, 300}, {0, 300}}, White)
+	board := [4]image.Point{{50, 40}, {350, 20}, {380, 280}, {20, 260}}
+	// a square in the middle of the board, drawn in the board's perspective
+	h, _ := HomographyFromPoints([4]image.Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}, board)
+	var square [4]image.Point
+	for i, p := range [4]image.Point{{30, 30}, {70, 30}, {70, 70}, {30, 70}} {
+		x, y := h.Apply(float64(p.X), float64(p.Y))
+		square[i] = image.Pt(int(math.Round(x)), int(math.Round(y)))
+	}
+	fillQuad(img, square, Blue)
+
+	data := CreateShapes(img, ShapeCreationOptions{Perspective: &PerspectiveOptions{Corners: board}})
+	if data.Transform == nil {
+		t.Fatalf("CreateShapes() did not record the transform")
+	}
+	if len(data.Shapes) != 1 {
+		t.Fatalf("CreateShapes() created %d shapes, want 1", len(data.Shapes))
+	}
+
+	shape := data.Shapes[0]
+	x, y := data.Transform.Apply(float64(shape.CornerX), float64(shape.CornerY))
+	if math.Hypot(x-float64(square[0].X), y-float64(square[0].Y)) > 4 {
+		t.Errorf("transformed shape corner = (%f, %f), want %v", x, y, square[0])
+	}
+	// once corrected, every vertex of the square's outline should be on the edges of its bounds
+	bounds := shape.Image.Bounds()
+	for _, v := range shape.Path {
+		x, y := int(v.X), int(v.Y)
+		if min(x, y, bounds.Dx()-1-x, bounds.Dy()-1-y) > 3 {
+			t.Errorf("CreateShapes() vertex %v is not on the edge of the shape's bounds %v", v, bounds)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package boardshapes
+
+import (
+	"math"
+	"slices"
+)
+
+type PrimitiveKind byte
+
+const (
+	PRIMITIVE_FREEFORM PrimitiveKind = iota
+	PRIMITIVE_LINE
+	PRIMITIVE_CIRCLE
+	PRIMITIVE_ELLIPSE
+	PRIMITIVE_RECTANGLE
+	PRIMITIVE_ROTATED_RECTANGLE
+	PRIMITIVE_TRIANGLE
+	PRIMITIVE_REGULAR_POLYGON
+)
+
+var primitiveKindNames = []string{
+	"freeform",
+	"line",
+	"circle",
+	"ellipse",
+	"rectangle",
+	"rotated-rectangle",
+	"triangle",
+	"regular-polygon",
+}
+
+func (pk PrimitiveKind) String() string {
+	if int(pk) < len(primitiveKindNames) {
+		return primitiveKindNames[pk]
+	}
+	return "unknown"
+}
+
+// Returns the kind with the given name (see [PrimitiveKind.String]), or false if there isn't one.
+func ParsePrimitiveKind(name string) (PrimitiveKind, bool) {
+	i := slices.Index(primitiveKindNames, name)
+	return PrimitiveKind(i), i != -1
+}
+
+// A geometric primitive that a shape was recognized as, fitted to the shape.
+// Positions are relative to the shape's corner, the same as its path.
+type Primitive struct {
+	Kind PrimitiveKind
+	// How well the primitive fits the shape, from 0 to 1.
+	// For freeform shapes, how badly the closest primitive fits.
+	Confidence float64
+	// The center of circles, ellipses, rectangles and regular polygons, or the midpoint of lines.
+	CenterX, CenterY float64
+	// The radii of circles and ellipses, half the size of rectangles, the distance from the center to the corners
+	// of regular polygons, or half the length of lines (in RadiusX).
+	RadiusX, RadiusY float64
+	// The rotation (clockwise on screen, in radians) of ellipses, rectangles, regular polygons and lines.
+	Angle float64
+	// The corners of rectangles, triangles and regular polygons, or the ends of lines.
+	Corners []Vertex
+}
+
+func (p *Primitive) Equal(other *Primitive) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	return p.Kind == other.Kind &&
+		p.Confidence == other.Confidence &&
+		p.CenterX == other.CenterX && p.CenterY == other.CenterY &&
+		p.RadiusX == other.RadiusX && p.RadiusY == other.RadiusY &&
+		p.Angle == other.Angle &&
+		slices.Equal(p.Corners, other.Corners)
+}
+
+// How far off (relative to the shape's size) a fit can be before its confidence reaches 0.
+const (
+	ELLIPSE_FIT_TOLERANCE = 0.15
+	POLYGON_FIT_TOLERANCE = 0.08
+	LINE_FIT_TOLERANCE    = 0.1
+)
+
+// Primitives that fit worse than this are freeform.
+const MINIMUM_PRIMITIVE_CONFIDENCE = 0.5
+
+// Ellipses with radii closer together than this ratio are circles.
+const MAXIMUM_CIRCLE_ASPECT_RATIO = 1.15
+
+// Corners of rectangles can be this far (in radians) from being right angles.
+const RECTANGLE_ANGLE_TOLERANCE = math.Pi / 12
+
+// Rectangles rotated less than this (in radians) are axis-aligned.
+const AXIS_ALIGNED_ANGLE_TOLERANCE = math.Pi / 36
+
+type point struct {
+	X, Y float64
+}
+
+func (a point) distanceTo(b point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// Returns the distance from the point to the line segment between a and b.
+func (p point) distanceToSegment(a, b point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return p.distanceTo(a)
+	}
+	t := max(0, min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lengthSquared))
+	return p.distanceTo(point{a.X + t*dx, a.Y + t*dy})
+}
+
+func (p point) vertex() Vertex {
+	return Vertex{uint16(max(0, math.Round(p.X))), uint16(max(0, math.Round(p.Y)))}
+}
+
+// Recognizes which geometric primitive a shape's path looks like, and fits it to the path.
+// Works best on paths that haven't been optimized yet.
+func RecognizePrimitive(path []Vertex, open bool) Primitive {
+	points := make([]point, len(path))
+	for i, v := range path {
+		points[i] = point{float64(v.X), float64(v.Y)}
+	}
+
+	if open {
+		return recognizeLine(points)
+	}
+	if len(points) < 3 {
+		return Primitive{Kind: PRIMITIVE_FREEFORM, Confidence: 1}
+	}
+
+	best := fitEllipse(points)
+	if polygon := fitPolygon(points); polygon.Confidence > best.Confidence {
+		best = polygon
+	}
+
+	if best.Confidence < MINIMUM_PRIMITIVE_CONFIDENCE {
+		return Primitive{Kind: PRIMITIVE_FREEFORM, Confidence: 1 - best.Confidence}
+	}
+	return best
+}
+
+func recognizeLine(points []point) Primitive {
+	if len(points) < 2 {
+		return Primitive{Kind: PRIMITIVE_FREEFORM, Confidence: 1}
+	}
+	a, b := points[0], points[len(points)-1]
+	length := a.distanceTo(b)
+
+	deviation := 0.0
+	for _, p := range points {
+		deviation = max(deviation, p.distanceToSegment(a, b))
+	}
+	confidence := 0.0
+	if length > 0 {
+		confidence = max(0, 1-deviation/length/LINE_FIT_TOLERANCE)
+	}
+
+	if confidence < MINIMUM_PRIMITIVE_CONFIDENCE {
+		return Primitive{Kind: PRIMITIVE_FREEFORM, Confidence: 1 - confidence}
+	}
+	return Primitive{
+		Kind:       PRIMITIVE_LINE,
+		Confidence: confidence,
+		CenterX:    (a.X + b.X) / 2,
+		CenterY:    (a.Y + b.Y) / 2,
+		RadiusX:    length / 2,
+		Angle:      math.Atan2(b.Y-a.Y, b.X-a.X),
+		Corners:    []Vertex{a.vertex(), b.vertex()},
+	}
+}
+
+// Returns the (absolute) area and centroid of the closed polygon.
+func polygonAreaAndCentroid(points []point) (area float64, centroid point) {
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		cross := a.X*b.Y - b.X*a.Y
+		area += cross
+		centroid.X += (a.X + b.X) * cross
+		centroid.Y += (a.Y + b.Y) * cross
+	}
+	area /= 2
+	if area == 0 {
+		return 0, points[0]
+	}
+	centroid.X /= 6 * area
+	centroid.Y /= 6 * area
+	return math.Abs(area), centroid
+}
+
+// Fits an ellipse with the same second moments of area as the polygon.
+func fitEllipse(points []point) Primitive {
+	area, centroid := polygonAreaAndCentroid(points)
+	if area == 0 {
+		return Primitive{}
+	}
+
+	// second moments of area about the centroid (Green's theorem)
+	var xx, yy, xy float64
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		ax, ay := a.X-centroid.X, a.Y-centroid.Y
+		bx, by := b.X-centroid.X, b.Y-centroid.Y
+		cross := ax*by - bx*ay
+		xx += (ax*ax + ax*bx + bx*bx) * cross
+		yy += (ay*ay + ay*by + by*by) * cross
+		xy += (ax*by + 2*ax*ay + 2*bx*by + bx*ay) * cross
+	}
+	sign := 1.0
+	if xx < 0 {
+		// the polygon runs counter-clockwise on screen
+		sign = -1
+	}
+	xx, yy, xy = sign*xx/12/area, sign*yy/12/area, sign*xy/24/area
+
+	// a filled ellipse's variance along each axis is a quarter of that axis' radius squared
+	spread := math.Sqrt((xx-yy)*(xx-yy)/4 + xy*xy)
+	major, minor := (xx+yy)/2+spread, max(0, (xx+yy)/2-spread)
+	radiusX, radiusY := 2*math.Sqrt(major), 2*math.Sqrt(minor)
+	angle := math.Atan2(2*xy, xx-yy) / 2
+	if radiusX == 0 || radiusY == 0 {
+		return Primitive{}
+	}
+
+	sin, cos := math.Sincos(-angle)
+	meanError := 0.0
+	for _, p := range points {
+		x, y := p.X-centroid.X, p.Y-centroid.Y
+		rx, ry := x*cos-y*sin, x*sin+y*cos
+		meanError += math.Abs(math.Hypot(rx/radiusX, ry/radiusY) - 1)
+	}
+	meanError /= float64(len(points))
+
+	primitive := Primitive{
+		Kind:       PRIMITIVE_ELLIPSE,
+		Confidence: max(0, 1-meanError/ELLIPSE_FIT_TOLERANCE),
+		CenterX:    centroid.X,
+		CenterY:    centroid.Y,
+		RadiusX:    radiusX,
+		RadiusY:    radiusY,
+		Angle:      angle,
+	}
+	if radiusX/radiusY < MAXIMUM_CIRCLE_ASPECT_RATIO {
+		primitive.Kind = PRIMITIVE_CIRCLE
+		primitive.RadiusX = (radiusX + radiusY) / 2
+		primitive.RadiusY = primitive.RadiusX
+		primitive.Angle = 0
+	}
+	return primitive
+}
+
+// Fits a polygon with only as many corners as the shape actually has, and recognizes which polygon it is.
+func fitPolygon(points []point) Primitive {
+	area, centroid := polygonAreaAndCentroid(points)
+	size := math.Sqrt(area)
+	if size == 0 {
+		return Primitive{}
+	}
+
+	corners := simplifyClosedPath(points, 0.05*size)
+	if len(corners) < 3 {
+		return Primitive{}
+	}
+
+	meanError := 0.0
+	for _, p := range points {
+		nearest := math.Inf(1)
+		for i, a := range corners {
+			nearest = min(nearest, p.distanceToSegment(a, corners[(i+1)%len(corners)]))
+		}
+		meanError += nearest
+	}
+	meanError /= float64(len(points)) * size
+	confidence := max(0, 1-meanError/POLYGON_FIT_TOLERANCE)
+
+	sides := make([]float64, len(corners))
+	for i, a := range corners {
+		sides[i] = a.distanceTo(corners[(i+1)%len(corners)])
+	}
+
+	switch {
+	case len(corners) == 3:
+		vertices := make([]Vertex, 3)
+		for i, c := range corners {
+			vertices[i] = c.vertex()
+		}
+		return Primitive{
+			Kind:       PRIMITIVE_TRIANGLE,
+			Confidence: confidence,
+			CenterX:    centroid.X,
+			CenterY:    centroid.Y,
+			Corners:    vertices,
+		}
+	case len(corners) == 4:
+		for i, b := range corners {
+			a, c := corners[(i+3)%4], corners[(i+1)%4]
+			angle := math.Abs(math.Atan2(a.Y-b.Y, a.X-b.X) - math.Atan2(c.Y-b.Y, c.X-b.X))
+			if angle > math.Pi {
+				angle = 2*math.Pi - angle
+			}
+			if math.Abs(angle-math.Pi/2) > RECTANGLE_ANGLE_TOLERANCE {
+				return Primitive{Kind: PRIMITIVE_FREEFORM, Confidence: 1 - confidence}
+			}
+		}
+		return fitRectangle(corners, sides, centroid, confidence)
+	default:
+		// regular polygons have sides of (about) the same length
+		mean, deviation := 0.0, 0.0
+		for _, side := range sides {
+			mean += side / float64(len(sides))
+		}
+		for _, side := range sides {
+			deviation = max(deviation, math.Abs(side-mean)/mean)
+		}
+		if deviation > 0.35 {
+			return Primitive{Kind: PRIMITIVE_FREEFORM, Confidence: 1 - confidence}
+		}
+
+		radius := 0.0
+		for _, c := range corners {
+			radius += c.distanceTo(centroid) / float64(len(corners))
+		}
+		angle := math.Atan2(corners[0].Y-centroid.Y, corners[0].X-centroid.X)
+		if signedPointArea(corners) < 0 {
+			slices.Reverse(corners[1:])
+		}
+		vertices := make([]Vertex, len(corners))
+		for i := range corners {
+			theta := angle + 2*math.Pi*float64(i)/float64(len(corners))
+			vertices[i] = point{centroid.X + radius*math.Cos(theta), centroid.Y + radius*math.Sin(theta)}.vertex()
+		}
+		return Primitive{
+			Kind:       PRIMITIVE_REGULAR_POLYGON,
+			Confidence: confidence,
+			CenterX:    centroid.X,
+			CenterY:    centroid.Y,
+			RadiusX:    radius,
+			RadiusY:    radius,
+			Angle:      angle,
+			Corners:    vertices,
+		}
+	}
+}
+
+func fitRectangle(corners []point, sides []float64, centroid point, confidence float64) Primitive {
+	// the angle of the first side, turned so that it's between -45 and 45 degrees
+	angle := math.Atan2(corners[1].Y-corners[0].Y, corners[1].X-corners[0].X)
+	halfWidth, halfHeight := (sides[0]+sides[2])/4, (sides[1]+sides[3])/4
+	for angle > math.Pi/4 {
+		angle -= math.Pi / 2
+		halfWidth, halfHeight = halfHeight, halfWidth
+	}
+	for angle <= -math.Pi/4 {
+		angle += math.Pi / 2
+		halfWidth, halfHeight = halfHeight, halfWidth
+	}
+
+	kind := PRIMITIVE_ROTATED_RECTANGLE
+	if math.Abs(angle) < AXIS_ALIGNED_ANGLE_TOLERANCE {
+		kind = PRIMITIVE_RECTANGLE
+		angle = 0
+	}
+
+	sin, cos := math.Sincos(angle)
+	vertices := make([]Vertex, 4)
+	for i, offset := range []point{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		x, y := offset.X*halfWidth, offset.Y*halfHeight
+		vertices[i] = point{centroid.X + x*cos - y*sin, centroid.Y + x*sin + y*cos}.vertex()
+	}
+
+	return Primitive{
+		Kind:       kind,
+		Confidence: confidence,
+		CenterX:    centroid.X,
+		CenterY:    centroid.Y,
+		RadiusX:    halfWidth,
+		RadiusY:    halfHeight,
+		Angle:      angle,
+		Corners:    vertices,
+	}
+}
+
+func signedPointArea(points []point) float64 {
+	area := 0.0
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area
+}
+
+// Simplifies the closed path with the Ramer-Douglas-Peucker algorithm,
+// splitting it at the two points furthest from each other first.
+func simplifyClosedPath(points []point, epsilon float64) []point {
+	first := 0
+	for i, p := range points {
+		if p.distanceTo(points[0]) > points[first].distanceTo(points[0]) {
+			first = i
+		}
+	}
+	second := first
+	for i, p := range points {
+		if p.distanceTo(points[first]) > points[second].distanceTo(points[first]) {
+			second = i
+		}
+	}
+	if first == second {
+		return points[:1]
+	}
+	if first > second {
+		first, second = second, first
+	}
+
+	half1 := points[first : second+1]
+	half2 := append(slices.Clone(points[second:]), points[:first+1]...)
+
+	simplified1, simplified2 := simplifyPath(half1, epsilon), simplifyPath(half2, epsilon)
+	// each half ends where the other starts
+	return append(simplified1[:len(simplified1)-1], simplified2[:len(simplified2)-1]...)
+}
+
+func simplifyPath(points []point, epsilon float64) []point {
+	if len(points) < 3 {
+		return points
+	}
+	a, b := points[0], points[len(points)-1]
+	furthest, furthestDistance := 0, -1.0
+	for i, p := range points[1 : len(points)-1] {
+		if d := p.distanceToSegment(a, b); d > furthestDistance {
+			furthest, furthestDistance = i+1, d
+		}
+	}
+	if furthestDistance > epsilon {
+		return append(
+			simplifyPath(points[:furthest+1], epsilon),
+			simplifyPath(points[furthest:], epsilon)[1:]...)
+	}
+	return []point{a, b}
+}
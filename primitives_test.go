@@ -0,0 +1,104 @@
+package boardshapes
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestRecognizePrimitive(t *testing.T) {
+	inPolygon := func(corners ...image.Point) func(x, y int) bool {
+		return func(x, y int) bool {
+			for i, a := range corners {
+				b := corners[(i+1)%len(corners)]
+				if (b.X-a.X)*(y-a.Y)-(b.Y-a.Y)*(x-a.X) < 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	hexagon := make([]image.Point, 6)
+	for i := range hexagon {
+		theta := math.Pi / 3 * float64(i)
+		hexagon[i] = image.Pt(100+int(math.Round(70*math.Cos(theta))), 100+int(math.Round(70*math.Sin(theta))))
+	}
+
+	tests := []struct {
+		name   string
+		inside func(x, y int) bool
+		want   PrimitiveKind
+	}{
+		{"circle", func(x, y int) bool { return math.Hypot(float64(x-100), float64(y-100)) < 60 }, PRIMITIVE_CIRCLE},
+		{"ellipse", func(x, y int) bool { return math.Hypot(float64(x-100)/80, float64(y-100)/40) < 1 }, PRIMITIVE_ELLIPSE},
+		{"rectangle", inPolygon(image.Pt(30, 50), image.Pt(170, 50), image.Pt(170, 140), image.Pt(30, 140)), PRIMITIVE_RECTANGLE},
+		{"rotated rectangle", inPolygon(image.Pt(60, 20), image.Pt(180, 90), image.Pt(140, 160), image.Pt(20, 90)), PRIMITIVE_ROTATED_RECTANGLE},
+		{"triangle", inPolygon(image.Pt(100, 20), image.Pt(180, 170), image.Pt(20, 170)), PRIMITIVE_TRIANGLE},
+		{"hexagon", inPolygon(hexagon...), PRIMITIVE_REGULAR_POLYGON},
+		{"L shape", func(x, y int) bool { return x > 20 && x < 180 && y > 20 && y < 180 && (x < 80 || y > 120) }, PRIMITIVE_FREEFORM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 200, 200))
+			for y := range 200 {
+				for x := range 200 {
+					if tt.inside(x, y) {
+						img.Set(x, y, Black)
+					} else {
+						img.Set(x, y, White)
+					}
+				}
+			}
+
+			data := CreateShapes(img, ShapeCreationOptions{RecognizePrimitives: true})
+			if len(data.Shapes) != 1 {
+				t.Fatalf("CreateShapes() returned %d shapes, want 1", len(data.Shapes))
+			}
+			primitive := data.Shapes[0].Primitive
+			if primitive == nil {
+				t.Fatal("CreateShapes() did not recognize a primitive")
+			}
+			if primitive.Kind != tt.want {
+				t.Errorf("RecognizePrimitive() kind = %v (confidence %.2f), want %v", primitive.Kind, primitive.Confidence, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecognizePrimitive_Circle(t *testing.T) {
+	path := make([]Vertex, 0)
+	for i := range 360 {
+		theta := 2 * math.Pi * float64(i) / 360
+		path = append(path, Vertex{uint16(math.Round(80 + 50*math.Cos(theta))), uint16(math.Round(60 + 50*math.Sin(theta)))})
+	}
+
+	primitive := RecognizePrimitive(path, false)
+	if primitive.Kind != PRIMITIVE_CIRCLE {
+		t.Fatalf("RecognizePrimitive() kind = %v, want %v", primitive.Kind, PRIMITIVE_CIRCLE)
+	}
+	if math.Abs(primitive.CenterX-80) > 1 || math.Abs(primitive.CenterY-60) > 1 {
+		t.Errorf("RecognizePrimitive() center = (%.1f, %.1f), want (80, 60)", primitive.CenterX, primitive.CenterY)
+	}
+	if math.Abs(primitive.RadiusX-50) > 1.5 {
+		t.Errorf("RecognizePrimitive() radius = %.1f, want 50", primitive.RadiusX)
+	}
+	if primitive.Confidence < 0.9 {
+		t.Errorf("RecognizePrimitive() confidence = %.2f, want at least 0.9", primitive.Confidence)
+	}
+}
+
+func TestRecognizePrimitive_Line(t *testing.T) {
+	path := []Vertex{{0, 0}, {10, 5}, {20, 10}, {30, 16}, {40, 20}}
+	primitive := RecognizePrimitive(path, true)
+	if primitive.Kind != PRIMITIVE_LINE {
+		t.Fatalf("RecognizePrimitive() kind = %v, want %v", primitive.Kind, PRIMITIVE_LINE)
+	}
+	if primitive.Corners[0] != path[0] || primitive.Corners[1] != path[len(path)-1] {
+		t.Errorf("RecognizePrimitive() ends = %v, want %v and %v", primitive.Corners, path[0], path[len(path)-1])
+	}
+
+	hook := []Vertex{{0, 0}, {40, 0}, {40, 30}}
+	if primitive := RecognizePrimitive(hook, true); primitive.Kind != PRIMITIVE_FREEFORM {
+		t.Errorf("RecognizePrimitive() kind = %v, want %v", primitive.Kind, PRIMITIVE_FREEFORM)
+	}
+}
@@ -8,11 +8,12 @@ import (
 	"image/color"
 	"math"
 	"slices"
+	"time"
 
 	"golang.org/x/image/draw"
 )
 
-const VERSION = "0.1.1"
+const VERSION = "0.2.0"
 
 // func manhattanDistance(a Vertex, b Vertex) int {
 // 	return absDiff(int(a.X), int(b.X)) + absDiff(int(a.Y), int(b.Y))
@@ -25,6 +26,7 @@ const (
 	REGION_PIXEL_VISITED   = 0b00000010
 	REGION_PIXEL_IS_OUTER  = 0b00000100
 	REGION_PIXEL_IS_INNER  = 0b00001000
+	REGION_PIXEL_IN_HOLE   = 0b00010000
 )
 
 func (r *RegionPixel) MarkInRegion() {
@@ -59,48 +61,102 @@ func (r RegionPixel) IsInner() bool {
 	return r&REGION_PIXEL_IS_INNER > 0
 }
 
+func (r *RegionPixel) MarkInHole() {
+	*r = *r | REGION_PIXEL_IN_HOLE
+}
+
+func (r RegionPixel) InHole() bool {
+	return r&REGION_PIXEL_IN_HOLE > 0
+}
+
 func (r RegionPixel) String() string {
 	return fmt.Sprintf("in region: %t; visited: %t; in shape: %t", r.InRegion(), r.Visited(), r.IsOuter())
 }
 
+var ErrRegionEmpty = errors.New("region-to-shape: region is empty")
+var ErrRegionTooThin = errors.New("region-to-shape: region is too thin")
+var ErrShapeGenerationFailed = errors.New("region-to-shape: shape generation failed")
+var ErrShapeNotClosed = errors.New("region-to-shape: could not close shape")
+var ErrRegionTooSmall = errors.New("region-to-shape: region is too small")
+
+// Traces the outline of the region, running clockwise (as seen on screen, with the y axis pointing down).
+// All vertices are relative to the top-left corner of the region's bounds.
+// Use [Region.CreateShapeWithHoles] to also trace the holes inside of it.
 func (region *Region) CreateShape() (shape []Vertex, err error) {
+	shape, _, _, err = region.CreateShapeWithHoles()
+	return shape, err
+}
+
+// Traces the outline of the region like [Region.CreateShape], along with the outline of every hole inside of it,
+// each running counter-clockwise.
+// Holes that cannot be traced are left out rather than failing the whole shape, and are counted in droppedHoles.
+func (region *Region) CreateShapeWithHoles() (shape []Vertex, holes [][]Vertex, droppedHoles int, err error) {
 	if len(*region) == 0 {
-		return nil, errors.New("region-to-shape: region is empty")
+		return nil, nil, 0, ErrRegionEmpty
 	}
 	regionBounds := region.GetBounds()
 
 	// will sastisfy my requirements.
 	regionPixels := createRegionPixelsMatrix(region, regionBounds)
 
+	// holes have to be found before findShapes visits them
+	possibleHoleVertices := findHoles(regionPixels)
+
 	// find inner pixels to find shapes
 	possibleShapeVertices := findShapes(regionPixels)
 
 	if len(possibleShapeVertices) == 0 {
-		return nil, errors.New("region-to-shape: region is too thin")
+		return nil, nil, 0, ErrRegionTooThin
 	}
 
 	largestShapeVertices := slices.MaxFunc(possibleShapeVertices, func(a, b []Vertex) int {
 		return cmp.Compare(len(a), len(b))
 	})
 
+	shape, err = traceShapeVertices(largestShapeVertices, regionBounds)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if signedArea(shape) < 0 {
+		slices.Reverse(shape)
+	}
+
+	holes = make([][]Vertex, 0, len(possibleHoleVertices))
+	for _, holeVertices := range possibleHoleVertices {
+		hole, err := traceShapeVertices(holeVertices, regionBounds)
+		if err != nil {
+			droppedHoles++
+			continue
+		}
+		if signedArea(hole) > 0 {
+			slices.Reverse(hole)
+		}
+		holes = append(holes, hole)
+	}
+
+	return shape, holes, droppedHoles, nil
+}
+
+// Sorts the given (unordered) outline pixels into a path.
+func traceShapeVertices(shapeVertices []Vertex, regionBounds image.Rectangle) ([]Vertex, error) {
 	vertexMatrix := make([][]bool, regionBounds.Dx())
 	for i := range vertexMatrix {
 		vertexMatrix[i] = make([]bool, regionBounds.Dy())
 	}
 
 	// build matrix with all vertices translated by (-1, -1)
-	// necessary because we added extra space for the region up above
-	for _, v := range largestShapeVertices {
+	// necessary because we added extra space for the region in createRegionPixelsMatrix
+	for _, v := range shapeVertices {
 		vertexMatrix[v.X-1][v.Y-1] = true
 	}
 
 	return findSortedShapeVertices(
 		Vertex{
-			X: largestShapeVertices[0].X - 1,
-			Y: largestShapeVertices[0].Y - 1,
+			X: shapeVertices[0].X - 1,
+			Y: shapeVertices[0].Y - 1,
 		},
 		vertexMatrix,
-		len(largestShapeVertices))
+		len(shapeVertices))
 }
 
 func createRegionPixelsMatrix(region *Region, regionBounds image.Rectangle) (regionPixels [][]RegionPixel) {
@@ -175,6 +231,46 @@ func findShapes(regionPixels [][]RegionPixel) [][]Vertex {
 	return possibleShapeVertices
 }
 
+// Finds every hole in the region (pixels outside of the region that can't reach the outside)
+// and returns the region pixels bordering each of them.
+func findHoles(regionPixels [][]RegionPixel) [][]Vertex {
+	possibleHoleVertices := make([][]Vertex, 0)
+	for y := uint16(0); y < uint16(len(regionPixels[0])); y++ {
+		for x := uint16(0); x < uint16(len(regionPixels)); x++ {
+			rp := regionPixels[x][y]
+			// outer pixels have already been visited
+			if !rp.Visited() && !rp.InRegion() && !rp.InHole() {
+				verticesToVisit := []Vertex{{x, y}}
+				newHole := make([]Vertex, 0)
+				// a pixel can border more than one hole, so edges are tracked per hole
+				isHoleEdge := make(map[Vertex]bool)
+				for len(verticesToVisit) > 0 {
+					v := verticesToVisit[len(verticesToVisit)-1]
+					verticesToVisit = verticesToVisit[:len(verticesToVisit)-1]
+					if regionPixels[v.X][v.Y].InHole() {
+						continue
+					}
+					regionPixels[v.X][v.Y].MarkInHole()
+					forNonDiagonalAdjacents(
+						v.X, v.Y, len(regionPixels), len(regionPixels[0]),
+						func(x, y uint16) {
+							if regionPixels[x][y].InRegion() {
+								if !isHoleEdge[Vertex{x, y}] {
+									isHoleEdge[Vertex{x, y}] = true
+									newHole = append(newHole, Vertex{x, y})
+								}
+							} else if !regionPixels[x][y].InHole() {
+								verticesToVisit = append(verticesToVisit, Vertex{x, y})
+							}
+						})
+				}
+				possibleHoleVertices = append(possibleHoleVertices, newHole)
+			}
+		}
+	}
+	return possibleHoleVertices
+}
+
 func findSortedShapeVertices(startingVertex Vertex, vertexMatrix [][]bool, maxLength int) ([]Vertex, error) {
 	var previousVertex Vertex
 	var isPreviousVertexSet = false
@@ -191,7 +287,7 @@ func findSortedShapeVertices(startingVertex Vertex, vertexMatrix [][]bool, maxLe
 		})
 
 		if len(adjacentVertices) != 2 {
-			return nil, errors.New("region-to-shape: shape generation failed")
+			return nil, ErrShapeGenerationFailed
 		}
 
 		if !isPreviousVertexSet {
@@ -215,7 +311,7 @@ func findSortedShapeVertices(startingVertex Vertex, vertexMatrix [][]bool, maxLe
 		}
 
 		if len(sortedShapeVertices) >= maxLength {
-			return nil, errors.New("region-to-shape: could not close shape")
+			return nil, ErrShapeNotClosed
 		}
 	}
 }
@@ -324,7 +420,7 @@ func ResizeImageTo(img image.Image, width, height int) image.Image {
 		width = int(math.Round(float64(bd.Dx()) * wScalar))
 	} else if height <= 0 {
 		hScalar := float64(width) / float64(bd.Dx())
-		height = int(math.Round(float64(bd.Dx()) * hScalar))
+		height = int(math.Round(float64(bd.Dy()) * hScalar))
 	} else {
 		wScalar := float64(height) / float64(bd.Dy())
 		hScalar := float64(width) / float64(bd.Dx())
@@ -338,41 +434,26 @@ func ResizeImageTo(img image.Image, width, height int) image.Image {
 	return scaledImg
 }
 
+// Simplifies every pixel of the image down to one of a few colors, using the classifier from the options.
 func SimplifyImage(img image.Image, options ShapeCreationOptions) (result image.Image) {
-	bd := img.Bounds()
-	var newImg *image.Paletted
+	options = options.withDiscoveredPalette(img)
+	classifier := options.getClassifier()
+
+	palette := color.Palette{}
 	if options.AllowWhite {
-		newImg = image.NewPaletted(bd, color.Palette{Blank, White, Black, Red, Green, Blue})
-	} else {
-		newImg = image.NewPaletted(bd, color.Palette{White, Black, Red, Green, Blue})
+		palette = append(palette, Blank)
+	}
+	for _, c := range classifier.Colors() {
+		palette = append(palette, c)
 	}
 
+	bd := img.Bounds()
+	newImg := image.NewPaletted(bd, palette)
+
 	for y := bd.Min.Y; y < bd.Max.Y; y++ {
 		for x := bd.Min.X; x < bd.Max.X; x++ {
-			c := GetNRGBA(img.At(x, y))
-			r, g, b, a := int(c.R), int(c.G), int(c.B), int(c.A)
-			var newPixelColor color.NRGBA
-			avg := (r + g + b) / 3
-			if a < 10 {
-				if options.AllowWhite {
-					newPixelColor = Blank
-				} else {
-					newPixelColor = White
-				}
-			} else if max(absDiff(avg, r), absDiff(avg, g), absDiff(avg, b)) < 10 {
-				// todo: better way to detect black maybe
-				if max(r, g, b) > 115 {
-					newPixelColor = White
-				} else {
-					newPixelColor = Black
-				}
-			} else if r > g && r > b {
-				newPixelColor = Red
-			} else if g > r && (g > b || b-g < 10) {
-				newPixelColor = Green
-			} else if b > r && b > g {
-				newPixelColor = Blue
-			} else {
+			newPixelColor := classifier.Classify(GetNRGBA(img.At(x, y)))
+			if newPixelColor == Blank && !options.AllowWhite {
 				newPixelColor = White
 			}
 			newImg.Set(x, y, newPixelColor)
@@ -384,13 +465,29 @@ func SimplifyImage(img image.Image, options ShapeCreationOptions) (result image.
 
 type BoardshapesData struct {
 	Version string
-	Shapes  []ShapeData
+	// The named colors that shapes were classified as.
+	Palette Palette
+	// If the perspective of the image was corrected, maps points in the image the shapes were created from
+	// (such as a shape's corner plus one of its vertices) back to the original image.
+	Transform *Homography
+	// Where the shapes came from and how they were created, if known.
+	Metadata *Metadata
+	Shapes   []ShapeData
 }
 
 func (bd BoardshapesData) Equal(other BoardshapesData) (equal bool, reason string) {
 	if bd.Version != other.Version {
 		return false, "version mismatch"
 	}
+	if !slices.Equal(bd.Palette.Entries, other.Palette.Entries) || bd.Palette.Tolerance != other.Palette.Tolerance {
+		return false, "palette mismatch"
+	}
+	if (bd.Transform == nil) != (other.Transform == nil) || (bd.Transform != nil && *bd.Transform != *other.Transform) {
+		return false, "transform mismatch"
+	}
+	if !bd.Metadata.Equal(other.Metadata) {
+		return false, "metadata mismatch"
+	}
 	if len(bd.Shapes) != len(other.Shapes) {
 		return false, "shape count mismatch"
 	}
@@ -413,11 +510,23 @@ type ShapeData struct {
 	CornerX   int
 	CornerY   int
 	Image     image.Image
-	Path      []Vertex
+	// The outline of the shape, running clockwise.
+	Path Path
+	// The outlines of any holes in the shape, each running counter-clockwise.
+	Holes []Path
+	// If true, the path is a line that doesn't loop back to its start, rather than an outline.
+	Open bool
+	// The geometric primitive the shape was recognized as, if primitives were recognized.
+	Primitive *Primitive
+	// The path as smooth curves, if curves were fitted.
+	Curves []CubicBezier
+	// The outlines of the holes as smooth curves, in the same order as the holes, if curves were fitted.
+	HoleCurves [][]CubicBezier
 }
 
 func (sd ShapeData) Equal(other ShapeData) bool {
 	if sd.Number != other.Number ||
+		sd.Open != other.Open ||
 		sd.Color != other.Color ||
 		sd.ColorName != other.ColorName ||
 		sd.CornerX != other.CornerX || sd.CornerY != other.CornerY {
@@ -438,7 +547,11 @@ func (sd ShapeData) Equal(other ShapeData) bool {
 			}
 		}
 	}
-	return slices.Equal(sd.Path, other.Path)
+	return slices.Equal(sd.Path, other.Path) &&
+		slices.EqualFunc(sd.Holes, other.Holes, slices.Equal) &&
+		sd.Primitive.Equal(other.Primitive) &&
+		slices.Equal(sd.Curves, other.Curves) &&
+		slices.EqualFunc(sd.HoleCurves, other.HoleCurves, slices.Equal)
 }
 
 type ShapeCreationOptions struct {
@@ -447,19 +560,205 @@ type ShapeCreationOptions struct {
 	PreserveColor,
 	KeepSmallRegions bool
 	EpsilonRDP float64
+	// Regions with fewer pixels than this are left out, unless KeepSmallRegions is set.
+	// Uses [DEFAULT_MIN_REGION_SIZE] if 0 or less.
+	MinRegionSize int
+	// Decides what color each pixel is simplified to.
+	// If nil, uses the classifier of the palette, or [DefaultColorClassifier] if the palette is also nil.
+	Classifier ColorClassifier
+	// Names the colors of shapes. Uses [DefaultPalette] if nil.
+	Palette *Palette
+	// If greater than 0, discovers up to this many ink colors from the image with [DiscoverPalette],
+	// replacing the classifier and palette.
+	DiscoverColors int
+	// If set, corrects the perspective of the image before anything else.
+	// If its corners aren't given and can't be found, [CreateShapes] uses the image as-is
+	// (recording that in the metadata's options), while [CreateShapesWithDiagnostics] returns an error.
+	Perspective *PerspectiveOptions
+	// If set, evens out the lighting of the image with [NormalizeIllumination] before simplifying it.
+	Illumination *IlluminationOptions
+	// If true, regions too thin to have an outline become open paths along their centerline instead of being left out.
+	ExtractCenterlines bool
+	// If true, recognizes which geometric primitive (circle, rectangle, etc.) each shape is with [RecognizePrimitive].
+	RecognizePrimitives bool
+	// If greater than 0, also fits each shape with smooth curves using [FitCurves],
+	// that stray no further than this (in pixels) from the shape.
+	CurveTolerance float64
+	// If true, the image isn't resized to fit 1920x1080 before creating shapes, such as when it's been resized already.
+	NoResize bool
+	// If true, records when the shapes were created in their metadata.
+	// Left out by default, so the same image and options always give the same data.
+	RecordCreationTime bool
+}
+
+// Replaces the classifier and palette with ones discovered from the image, if the options ask for it.
+func (opts ShapeCreationOptions) withDiscoveredPalette(img image.Image) ShapeCreationOptions {
+	if opts.DiscoverColors > 0 {
+		discovered := DiscoverPalette(img, opts.DiscoverColors)
+		opts.Classifier = discovered.Classifier()
+		opts.Palette = &discovered.Palette
+		// don't discover them again when passing these options along
+		opts.DiscoverColors = 0
+	}
+	return opts
+}
+
+func (opts ShapeCreationOptions) getClassifier() ColorClassifier {
+	if opts.Classifier != nil {
+		return opts.Classifier
+	}
+	if opts.Palette != nil {
+		return opts.Palette.Classifier()
+	}
+	return DefaultColorClassifier
+}
+
+func (opts ShapeCreationOptions) getPalette() Palette {
+	if opts.Palette != nil {
+		return *opts.Palette
+	}
+	return DefaultPalette
+}
+
+const DEFAULT_MIN_REGION_SIZE = 50
+
+func isRegionLargeEnough(region *Region, minSize int) bool {
+	if minSize <= 0 {
+		minSize = DEFAULT_MIN_REGION_SIZE
+	}
+	return len(*region) >= minSize
+}
+
+// An image prepared for creating shapes from it, by [PrepareImage].
+type PreparedImage struct {
+	Image image.Image
+	// The bounds of the image before it was prepared.
+	SourceBounds image.Rectangle
+	// How much the image was scaled by when resizing it, after correcting its perspective if it was.
+	Scale float64
+	// If the perspective was corrected, the transform from the prepared image back to the source image.
+	Transform *Homography
+	// Why the perspective couldn't be corrected, if [PrepareImageWithFallback] prepared the image without doing so.
+	PerspectiveErr error
+}
+
+// Runs the stages of [CreateShapes] that come before simplifying the image:
+// correcting its perspective, resizing it (unless the options say not to) and normalizing its illumination.
+func PrepareImage(img image.Image, opts ShapeCreationOptions) (*PreparedImage, error) {
+	prepared := &PreparedImage{SourceBounds: img.Bounds(), Scale: 1}
+	if opts.Perspective != nil {
+		corrected, h, err := opts.Perspective.correct(img)
+		if err != nil {
+			return nil, err
+		}
+		img, prepared.Transform = corrected, &h
+	}
+
+	originalBounds := img.Bounds()
+	if !opts.NoResize {
+		img = ResizeImage(img)
+	}
+	if originalBounds.Dx() > 0 {
+		prepared.Scale = float64(img.Bounds().Dx()) / float64(originalBounds.Dx())
+	}
+
+	if prepared.Transform != nil {
+		// also undo the resize
+		resized := prepared.Transform.Multiply(ScaleHomography(
+			float64(originalBounds.Dx())/float64(img.Bounds().Dx()),
+			float64(originalBounds.Dy())/float64(img.Bounds().Dy())))
+		prepared.Transform = &resized
+	}
+
+	if opts.Illumination != nil {
+		img = NormalizeIllumination(img, *opts.Illumination)
+	}
+
+	prepared.Image = img
+	return prepared, nil
+}
+
+// Prepares the image like [PrepareImage], but if its perspective can't be corrected,
+// prepares it without correcting it and keeps the error in PerspectiveErr.
+func PrepareImageWithFallback(img image.Image, opts ShapeCreationOptions) *PreparedImage {
+	prepared, err := PrepareImage(img, opts)
+	if err != nil {
+		// only correcting the perspective can fail
+		opts.Perspective = nil
+		prepared, _ = PrepareImage(img, opts)
+		prepared.PerspectiveErr = err
+	}
+	return prepared
+}
+
+// Why a region did or didn't become a shape.
+type RegionDiagnostic struct {
+	// The index of the region, which is also the number of its shape.
+	// Regions removed for being too small don't have an index, so it's -1 for them.
+	Index      int
+	Bounds     image.Rectangle
+	PixelCount int
+	Color      color.Color
+	// Why the region didn't become a shape (such as [ErrRegionTooThin]), or nil if it did.
+	Err error
+	// The outline (or centerline) and holes traced from the region, before they were optimized.
+	// Relative to the top-left corner of Bounds, like the paths of shapes. Nil if the region didn't become a shape.
+	RawPath  Path
+	RawHoles []Path
+	// How many holes in the region were left out of its shape, because they couldn't be traced,
+	// optimized down to a line, or ended up outside of the optimized outline.
+	DroppedHoles int
 }
 
-func isRegionLargeEnough(region *Region) bool {
-	const MINIMUM_NUMBER_OF_PIXELS_FOR_NON_SMALL_REGION = 50
-	return len(*region) >= MINIMUM_NUMBER_OF_PIXELS_FOR_NON_SMALL_REGION
+type ShapeCreationResult struct {
+	Data        *BoardshapesData
+	Diagnostics []RegionDiagnostic
+	// The image the shapes were created from, after it was prepared.
+	Prepared *PreparedImage
 }
 
+// Creates shapes from the image. If the perspective of the image can't be corrected, the image is used as-is.
+// Regions that can't be turned into shapes are left out; use [CreateShapesWithDiagnostics] to find out which.
 func CreateShapes(img image.Image, opts ShapeCreationOptions) (data *BoardshapesData) {
-	data = &BoardshapesData{
-		Version: VERSION,
+	return createShapes(PrepareImageWithFallback(img, opts), opts).Data
+}
+
+// Creates shapes from the image like [CreateShapes], along with a diagnostic for every region found in it.
+// Returns an error if the image couldn't be prepared, such as when the corners of the board can't be found.
+func CreateShapesWithDiagnostics(img image.Image, opts ShapeCreationOptions) (*ShapeCreationResult, error) {
+	prepared, err := PrepareImage(img, opts)
+	if err != nil {
+		return nil, err
 	}
+	return createShapes(prepared, opts), nil
+}
 
-	img = ResizeImage(img)
+// Creates shapes from the prepared image, for [CreateShapes] and [CreateShapesWithDiagnostics].
+func createShapes(prepared *PreparedImage, opts ShapeCreationOptions) *ShapeCreationResult {
+	img := prepared.Image
+	metadata := &Metadata{
+		ImageWidth:   img.Bounds().Dx(),
+		ImageHeight:  img.Bounds().Dy(),
+		SourceWidth:  prepared.SourceBounds.Dx(),
+		SourceHeight: prepared.SourceBounds.Dy(),
+		Scale:        prepared.Scale,
+		Options:      opts.Summary(),
+	}
+	if prepared.PerspectiveErr != nil {
+		metadata.Options["perspective"] = "failed"
+	}
+	if opts.RecordCreationTime {
+		metadata.CreatedAt = time.Now().UTC()
+	}
+	opts = opts.withDiscoveredPalette(img)
+	palette := opts.getPalette()
+	data := &BoardshapesData{
+		Version:   VERSION,
+		Palette:   Palette{Entries: slices.Clone(palette.Entries)},
+		Transform: prepared.Transform,
+		Metadata:  metadata,
+	}
+	diagnostics := make([]RegionDiagnostic, 0)
 
 	newImg := SimplifyImage(img, opts)
 
@@ -467,7 +766,19 @@ func CreateShapes(img image.Image, opts ShapeCreationOptions) (data *Boardshapes
 	if opts.KeepSmallRegions {
 		filter = nil
 	} else {
-		filter = isRegionLargeEnough
+		filter = func(region *Region) bool {
+			if isRegionLargeEnough(region, opts.MinRegionSize) {
+				return true
+			}
+			diagnostics = append(diagnostics, RegionDiagnostic{
+				Index:      -1,
+				Bounds:     region.GetBounds(),
+				PixelCount: len(*region),
+				Color:      GetColorOfRegion(region, newImg, opts.NoColorSeparation),
+				Err:        ErrRegionTooSmall,
+			})
+			return false
+		}
 	}
 
 	regionMap := BuildRegionMap(newImg, opts, filter)
@@ -482,20 +793,7 @@ func CreateShapes(img image.Image, opts ShapeCreationOptions) (data *Boardshapes
 
 		minX, minY := FindRegionPosition(region)
 		regionColor := GetColorOfRegion(region, newImg, opts.NoColorSeparation)
-		var regionColorName string
-
-		switch regionColor {
-		case Red:
-			regionColorName = "Red"
-		case Green:
-			regionColorName = "Green"
-		case Blue:
-			regionColorName = "Blue"
-		case Black:
-			regionColorName = "Black"
-		case White:
-			regionColorName = "White"
-		}
+		regionColorName := palette.Name(regionColor)
 
 		regionImage := image.NewNRGBA(region.GetBounds())
 
@@ -509,29 +807,97 @@ func CreateShapes(img image.Image, opts ShapeCreationOptions) (data *Boardshapes
 			}
 		}
 
-		shape, err := region.CreateShape()
+		shape, holes, droppedHoles, err := region.CreateShapeWithHoles()
+		open := false
+		if err == ErrRegionTooThin && opts.ExtractCenterlines {
+			if centerline, centerlineErr := region.CreateCenterline(); centerlineErr == nil {
+				shape, open, err = centerline, true, nil
+			}
+		}
+
+		diagnostic := RegionDiagnostic{
+			Index:      i,
+			Bounds:     regionImage.Bounds(),
+			PixelCount: len(*region),
+			Color:      regionColor,
+			Err:        err,
+		}
 		if err != nil {
+			diagnostics = append(diagnostics, diagnostic)
 			continue
 		}
+		// optimizing modifies paths in place, so these have to be copies
+		diagnostic.RawPath = slices.Clone(shape)
+		diagnostic.RawHoles = make([]Path, len(holes))
+		for j, hole := range holes {
+			diagnostic.RawHoles[j] = slices.Clone(hole)
+		}
+		diagnosticIndex := len(diagnostics)
+		diagnostics = append(diagnostics, diagnostic)
+
+		epsilon := opts.EpsilonRDP
+		if epsilon == 0 {
+			epsilon = DEFAULT_RDP_EPSILON
+		}
+		optimize := func(shape []Vertex) []Vertex {
+			return OptimizeShapeWithEpsilon(shape, epsilon)
+		}
+
+		// primitives are fitted to the unoptimized path, which follows the region more closely
+		var primitive *Primitive
+		if opts.RecognizePrimitives {
+			recognized := RecognizePrimitive(shape, open)
+			primitive = &recognized
+		}
 
-		if opts.EpsilonRDP == 0 {
-			shape = OptimizeShape(shape)
+		var curves []CubicBezier
+		var holeCurves [][]CubicBezier
+		if opts.CurveTolerance > 0 {
+			curves = FitCurves(shape, !open, opts.CurveTolerance)
+		}
+
+		if open {
+			shape = OptimizeOpenPathWithEpsilon(shape, epsilon)
 		} else {
-			shape = OptimizeShapeWithEpsilon(shape, opts.EpsilonRDP)
+			shape = optimize(shape)
+		}
+
+		optimizedHoles := make([]Path, 0, len(holes))
+		for _, hole := range holes {
+			var curves []CubicBezier
+			if opts.CurveTolerance > 0 {
+				curves = FitCurves(hole, true, opts.CurveTolerance)
+			}
+			// holes that optimize down to a line (or nothing) aren't worth keeping,
+			// and neither are holes the optimized outline no longer goes around
+			if hole = optimize(hole); len(hole) < 3 || !isPathInside(hole, shape) {
+				droppedHoles++
+				continue
+			}
+			optimizedHoles = append(optimizedHoles, hole)
+			if curves != nil {
+				holeCurves = append(holeCurves, curves)
+			}
 		}
+		diagnostics[diagnosticIndex].DroppedHoles = droppedHoles
 
 		shapeData := ShapeData{
-			Number:    i,
-			Color:     regionColor,
-			ColorName: regionColorName,
-			CornerX:   minX,
-			CornerY:   minY,
-			Image:     regionImage,
-			Path:      shape,
+			Number:     i,
+			Color:      regionColor,
+			ColorName:  regionColorName,
+			CornerX:    minX,
+			CornerY:    minY,
+			Image:      regionImage,
+			Path:       shape,
+			Holes:      optimizedHoles,
+			Open:       open,
+			Primitive:  primitive,
+			Curves:     curves,
+			HoleCurves: holeCurves,
 		}
 
 		data.Shapes = append(data.Shapes, shapeData)
 	}
 
-	return
+	return &ShapeCreationResult{Data: data, Diagnostics: diagnostics, Prepared: prepared}
 }
@@ -129,6 +129,23 @@ func generateTestResultImage(name string, shape *[]Vertex) error {
 	return file.Close()
 }
 
+func TestResizeImageTo(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	tests := []struct {
+		width, height int
+		want          image.Point
+	}{
+		{100, 0, image.Pt(100, 50)},
+		{0, 100, image.Pt(200, 100)},
+		{100, 100, image.Pt(100, 50)},
+	}
+	for _, tt := range tests {
+		if got := ResizeImageTo(img, tt.width, tt.height).Bounds().Size(); got != tt.want {
+			t.Errorf("ResizeImageTo(400x200, %d, %d) = %v, want %v", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
+
 var vertexMapNameRegex = regexp.MustCompile(`^test_(\w+)_vertexmap`)
 
 func TestRegion_CreateShape(t *testing.T) {
@@ -225,3 +242,151 @@ func TestRegion_CreateShape(t *testing.T) {
 		})
 	}
 }
+
+func TestRegion_CreateShapeWithHoles(t *testing.T) {
+	// a 12x12 square with two 3x3 windows cut out of it
+	region := make(Region, 0)
+	for y := range 12 {
+		for x := range 12 {
+			inWindow := y >= 3 && y < 6 && ((x >= 2 && x < 5) || (x >= 7 && x < 10))
+			if !inWindow {
+				region = append(region, Pixel{uint16(x + 5), uint16(y + 5)})
+			}
+		}
+	}
+
+	shape, holes, droppedHoles, err := region.CreateShapeWithHoles()
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if droppedHoles != 0 {
+		t.Errorf("Region.CreateShapeWithHoles() dropped %d holes, want 0", droppedHoles)
+	}
+
+	if len(shape) != 40 {
+		t.Errorf("Region.CreateShapeWithHoles() outline has %d vertices, want 40", len(shape))
+	}
+	if signedArea(shape) <= 0 {
+		t.Errorf("Region.CreateShapeWithHoles() outline is not clockwise")
+	}
+
+	if len(holes) != 2 {
+		t.Fatalf("Region.CreateShapeWithHoles() returned %d holes, want 2", len(holes))
+	}
+	for i, hole := range holes {
+		if len(hole) != 12 {
+			t.Errorf("Region.CreateShapeWithHoles() hole %d has %d vertices, want 12", i, len(hole))
+		}
+		if signedArea(hole) >= 0 {
+			t.Errorf("Region.CreateShapeWithHoles() hole %d is not counter-clockwise", i)
+		}
+		for _, v := range hole {
+			if v.X >= 12 || v.Y >= 12 {
+				t.Errorf("Region.CreateShapeWithHoles() hole %d has vertex %v outside of the region's bounds", i, v)
+			}
+		}
+	}
+}
+
+func TestCreateShapesWithDiagnostics(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 200))
+	for y := range 200 {
+		for x := range 200 {
+			switch {
+			case x == 40 && y == 40:
+				// a hole too small to keep
+				img.Set(x, y, White)
+			case x >= 20 && x < 60 && y >= 20 && y < 60:
+				img.Set(x, y, Red)
+			case x >= 80 && x < 180 && y == 100:
+				img.Set(x, y, Blue)
+			case x >= 150 && x < 153 && y >= 150 && y < 153:
+				img.Set(x, y, Black)
+			default:
+				img.Set(x, y, White)
+			}
+		}
+	}
+
+	result, err := CreateShapesWithDiagnostics(img, ShapeCreationOptions{})
+	if err != nil {
+		t.Fatalf("CreateShapesWithDiagnostics() error = %v", err)
+	}
+
+	if len(result.Data.Shapes) != 1 {
+		t.Errorf("CreateShapesWithDiagnostics() created %d shapes, want 1", len(result.Data.Shapes))
+	}
+	if result.Prepared == nil || result.Prepared.Image.Bounds() != img.Bounds() {
+		t.Errorf("CreateShapesWithDiagnostics() prepared image = %v, want the 200x200 image", result.Prepared)
+	}
+
+	wantErrs := map[color.Color]error{Red: nil, Blue: ErrRegionTooThin, Black: ErrRegionTooSmall}
+	if len(result.Diagnostics) != len(wantErrs) {
+		t.Fatalf("CreateShapesWithDiagnostics() returned %d diagnostics, want %d", len(result.Diagnostics), len(wantErrs))
+	}
+	for _, diagnostic := range result.Diagnostics {
+		if want := wantErrs[diagnostic.Color]; diagnostic.Err != want {
+			t.Errorf("diagnostic for %v region has error %v, want %v", diagnostic.Color, diagnostic.Err, want)
+		}
+		if diagnostic.Color == Red && (len(diagnostic.RawHoles) != 1 || diagnostic.DroppedHoles != 1) {
+			t.Errorf("diagnostic for red region has %d raw holes and %d dropped, want 1 of each",
+				len(diagnostic.RawHoles), diagnostic.DroppedHoles)
+		}
+	}
+
+	// the red square is 1599 pixels
+	result, err = CreateShapesWithDiagnostics(img, ShapeCreationOptions{MinRegionSize: 2000})
+	if err != nil {
+		t.Fatalf("CreateShapesWithDiagnostics() error = %v", err)
+	}
+	if len(result.Data.Shapes) != 0 {
+		t.Errorf("CreateShapesWithDiagnostics() with a larger minimum region size created %d shapes, want 0", len(result.Data.Shapes))
+	}
+
+	badCorners := &PerspectiveOptions{Corners: [4]image.Point{{5, 5}, {5, 5}, {5, 5}, {5, 5}}}
+	if _, err := CreateShapesWithDiagnostics(img, ShapeCreationOptions{Perspective: badCorners}); err != ErrDegenerateCorners {
+		t.Errorf("CreateShapesWithDiagnostics() with bad corners error = %v, want %v", err, ErrDegenerateCorners)
+	}
+	data := CreateShapes(img, ShapeCreationOptions{Perspective: badCorners})
+	if len(data.Shapes) != 1 {
+		t.Errorf("CreateShapes() should fall back to the uncorrected image when the perspective can't be corrected")
+	}
+	if got := data.Metadata.Options["perspective"]; got != "failed" {
+		t.Errorf("CreateShapes() metadata perspective option = %q, want %q", got, "failed")
+	}
+	if prepared := PrepareImageWithFallback(img, ShapeCreationOptions{Perspective: badCorners}); prepared.PerspectiveErr != ErrDegenerateCorners {
+		t.Errorf("PrepareImageWithFallback() PerspectiveErr = %v, want %v", prepared.PerspectiveErr, ErrDegenerateCorners)
+	}
+}
+
+func TestCreateShapesMetadata(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3840, 2160))
+	for y := 200; y < 600; y++ {
+		for x := 200; x < 600; x++ {
+			img.Set(x, y, Black)
+		}
+	}
+
+	data := CreateShapes(img, ShapeCreationOptions{})
+	want := Metadata{ImageWidth: 1920, ImageHeight: 1080, SourceWidth: 3840, SourceHeight: 2160, Scale: 0.5}
+	if m := data.Metadata; m.ImageWidth != want.ImageWidth || m.ImageHeight != want.ImageHeight ||
+		m.SourceWidth != want.SourceWidth || m.SourceHeight != want.SourceHeight || m.Scale != want.Scale {
+		t.Errorf("CreateShapes() metadata = %+v, want %+v", *m, want)
+	}
+	if !data.Metadata.CreatedAt.IsZero() {
+		t.Errorf("CreateShapes() recorded the creation time without being asked to")
+	}
+	unresized := CreateShapes(img, ShapeCreationOptions{NoResize: true})
+	if m := unresized.Metadata; m.ImageWidth != 3840 || m.ImageHeight != 2160 || m.Scale != 1 || m.Options["noResize"] != "true" {
+		t.Errorf("CreateShapes() with NoResize metadata = %+v, want a 3840x2160 image at scale 1", *m)
+	}
+	if data := CreateShapes(img, ShapeCreationOptions{RecordCreationTime: true}); data.Metadata.CreatedAt.IsZero() {
+		t.Errorf("CreateShapes() with RecordCreationTime didn't record the creation time")
+	}
+
+	// as if the image had been resized from 7680x4320 before creating shapes from it
+	data.SetSourceSize(7680, 4320)
+	if m := data.Metadata; m.SourceWidth != 7680 || m.SourceHeight != 4320 || m.Scale != 0.25 {
+		t.Errorf("BoardshapesData.SetSourceSize() metadata = %+v, want a 7680x4320 source scaled by 0.25", *m)
+	}
+}
@@ -0,0 +1,73 @@
+package boardshapes
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// Returns the part of the image that the shape's path (and curves, if it has them) covers.
+func (sd ShapeData) Bounds() image.Rectangle {
+	maxX, maxY := 0.0, 0.0
+	for _, v := range sd.Path {
+		maxX, maxY = max(maxX, float64(v.X)), max(maxY, float64(v.Y))
+	}
+	for _, curve := range sd.Curves {
+		// curves stay within their control points
+		for _, p := range []CurvePoint{curve.Start, curve.Control1, curve.Control2, curve.End} {
+			maxX, maxY = max(maxX, float64(p.X)), max(maxY, float64(p.Y))
+		}
+	}
+	return image.Rect(sd.CornerX, sd.CornerY, sd.CornerX+int(math.Ceil(maxX))+1, sd.CornerY+int(math.Ceil(maxY))+1)
+}
+
+// Fills in the area inside the shape's outline and outside its holes (using their curves, if it has them),
+// with anti-aliased edges. The mask covers [ShapeData.Bounds].
+// Open shapes don't enclose anything, so their masks are empty.
+func (sd ShapeData) Mask() *image.Alpha {
+	bounds := sd.Bounds()
+	mask := image.NewAlpha(bounds)
+	if sd.Open {
+		return mask
+	}
+
+	// holes run the opposite way to the outline, so they're cut out of it
+	r := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	if len(sd.Curves) > 0 {
+		rasterizeCurves(r, sd.Curves)
+		for _, curves := range sd.HoleCurves {
+			rasterizeCurves(r, curves)
+		}
+	} else {
+		rasterizePolygon(r, sd.Path)
+		for _, hole := range sd.Holes {
+			rasterizePolygon(r, hole)
+		}
+	}
+	r.Draw(mask, bounds, image.Opaque, image.Point{})
+
+	return mask
+}
+
+func rasterizePolygon(r *vector.Rasterizer, path []Vertex) {
+	if len(path) < 3 {
+		return
+	}
+	r.MoveTo(float32(path[0].X), float32(path[0].Y))
+	for _, v := range path[1:] {
+		r.LineTo(float32(v.X), float32(v.Y))
+	}
+	r.ClosePath()
+}
+
+func rasterizeCurves(r *vector.Rasterizer, curves []CubicBezier) {
+	if len(curves) == 0 {
+		return
+	}
+	r.MoveTo(curves[0].Start.X, curves[0].Start.Y)
+	for _, c := range curves {
+		r.CubeTo(c.Control1.X, c.Control1.Y, c.Control2.X, c.Control2.Y, c.End.X, c.End.Y)
+	}
+	r.ClosePath()
+}
@@ -0,0 +1,34 @@
+package boardshapes
+
+import (
+	"image"
+	"testing"
+)
+
+func TestShapeData_Mask(t *testing.T) {
+	shape := ShapeData{
+		CornerX: 10,
+		CornerY: 20,
+		Path:    []Vertex{{0, 0}, {30, 0}, {30, 30}, {0, 30}},
+		Holes:   []Path{{{10, 10}, {10, 20}, {20, 20}, {20, 10}}},
+	}
+
+	mask := shape.Mask()
+	if want := image.Rect(10, 20, 41, 51); mask.Bounds() != want {
+		t.Errorf("ShapeData.Mask() bounds = %v, want %v", mask.Bounds(), want)
+	}
+	if a := mask.AlphaAt(15, 25).A; a != 255 {
+		t.Errorf("ShapeData.Mask() alpha inside the outline = %d, want 255", a)
+	}
+	if a := mask.AlphaAt(25, 35).A; a != 0 {
+		t.Errorf("ShapeData.Mask() alpha inside the hole = %d, want 0", a)
+	}
+	if a := mask.AlphaAt(40, 50).A; a != 0 {
+		t.Errorf("ShapeData.Mask() alpha outside the outline = %d, want 0", a)
+	}
+
+	shape.Open = true
+	if a := shape.Mask().AlphaAt(15, 25).A; a != 0 {
+		t.Errorf("ShapeData.Mask() alpha of an open shape = %d, want 0", a)
+	}
+}
@@ -54,6 +54,9 @@ type Vertex struct {
 	Y uint16 `json:"y"`
 }
 
+// A list of vertices, such as the outline of a shape.
+type Path []Vertex
+
 type Region []Pixel
 
 type RegionMap struct {
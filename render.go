@@ -0,0 +1,225 @@
+package boardshapes
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+const (
+	RENDER_OUTLINE_WIDTH = 1.5
+	RENDER_VERTEX_SIZE   = 3
+	// Open shapes without images are drawn as lines this wide.
+	RENDER_OPEN_SHAPE_WIDTH  = 3
+	RENDER_LEGEND_ROW_HEIGHT = 16
+)
+
+var DefaultOutlineColor = color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+
+type RenderOptions struct {
+	// Fills the canvas before anything is drawn on it. If nil, the canvas is left transparent.
+	Background color.Color
+	// Draws each shape's path and holes over its image.
+	Outlines bool
+	// Marks each vertex of the paths.
+	Vertices bool
+	// Labels each shape with its number, at its corner.
+	Numbers bool
+	// Adds a legend below the shapes, with each color and how many shapes have it.
+	Legend bool
+	// The color of outlines and vertex markers. Uses [DefaultOutlineColor] if nil.
+	OutlineColor color.Color
+}
+
+// Draws the shapes onto a canvas the size of the image they came from (if the data's metadata has it),
+// or else just big enough for all of them. Each shape's image is drawn at its corner,
+// and shapes without images are filled in with their color.
+func Render(data *BoardshapesData, options RenderOptions) *image.NRGBA {
+	shapesBounds := image.Rectangle{}
+	if data.Metadata != nil {
+		shapesBounds = image.Rect(0, 0, data.Metadata.ImageWidth, data.Metadata.ImageHeight)
+	}
+	for _, shape := range data.Shapes {
+		shapesBounds = shapesBounds.Union(shapeImageBounds(shape))
+	}
+	shapesBounds.Min = image.Point{}
+
+	legend := legendEntries(data)
+	canvasBounds := shapesBounds
+	if options.Legend {
+		canvasBounds.Max.Y += len(legend)*RENDER_LEGEND_ROW_HEIGHT + 4
+		for _, entry := range legend {
+			canvasBounds.Max.X = max(canvasBounds.Max.X, 24+font.MeasureString(basicfont.Face7x13, entry.label).Ceil())
+		}
+	}
+
+	canvas := image.NewNRGBA(canvasBounds)
+	if options.Background != nil {
+		draw.Draw(canvas, canvasBounds, image.NewUniform(options.Background), image.Point{}, draw.Src)
+	}
+
+	for _, shape := range data.Shapes {
+		if shape.Image != nil {
+			draw.Draw(canvas, shapeImageBounds(shape), shape.Image, shape.Image.Bounds().Min, draw.Over)
+		} else if shape.Open {
+			strokePaths(canvas, shape, []Path{shape.Path}, false, RENDER_OPEN_SHAPE_WIDTH, shape.Color)
+		} else {
+			mask := shape.Mask()
+			draw.DrawMask(canvas, mask.Bounds(), image.NewUniform(shape.Color), image.Point{}, mask, mask.Bounds().Min, draw.Over)
+		}
+	}
+
+	outlineColor := options.OutlineColor
+	if outlineColor == nil {
+		outlineColor = DefaultOutlineColor
+	}
+	for _, shape := range data.Shapes {
+		if options.Outlines {
+			strokePaths(canvas, shape, append([]Path{shape.Path}, shape.Holes...), !shape.Open, RENDER_OUTLINE_WIDTH, outlineColor)
+		}
+		if options.Vertices {
+			drawVertexMarkers(canvas, shape, append([]Path{shape.Path}, shape.Holes...), outlineColor)
+		}
+	}
+
+	if options.Numbers {
+		for _, shape := range data.Shapes {
+			drawLabel(canvas, fmt.Sprint(shape.Number), shape.CornerX, shape.CornerY)
+		}
+	}
+
+	if options.Legend {
+		for i, entry := range legend {
+			drawLegendRow(canvas, entry.color, entry.label, 4, shapesBounds.Max.Y+4+i*RENDER_LEGEND_ROW_HEIGHT)
+		}
+	}
+
+	return canvas
+}
+
+// Where the shape's image goes on the canvas. Images are placed at the shape's corner,
+// whether their bounds start there (as when shapes are created) or at the origin (as when they're deserialized).
+func shapeImageBounds(shape ShapeData) image.Rectangle {
+	if shape.Image == nil {
+		return shape.Bounds()
+	}
+	bounds := shape.Image.Bounds()
+	return bounds.Sub(bounds.Min).Add(image.Pt(shape.CornerX, shape.CornerY))
+}
+
+type legendEntry struct {
+	color color.Color
+	label string
+}
+
+// Returns an entry for each color, in the order the colors first appear.
+func legendEntries(data *BoardshapesData) []legendEntry {
+	entries := make([]legendEntry, 0)
+	indices := make(map[color.NRGBA]int)
+	counts := make([]int, 0)
+	names := make([]string, 0)
+	for _, shape := range data.Shapes {
+		nrgba := GetNRGBA(shape.Color)
+		i, ok := indices[nrgba]
+		if !ok {
+			i = len(entries)
+			indices[nrgba] = i
+			entries = append(entries, legendEntry{color: nrgba})
+			counts = append(counts, 0)
+			name := shape.ColorName
+			if name == "" {
+				name = fmt.Sprintf("#%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B)
+			}
+			names = append(names, name)
+		}
+		counts[i]++
+	}
+	for i := range entries {
+		shapes := "shapes"
+		if counts[i] == 1 {
+			shapes = "shape"
+		}
+		entries[i].label = fmt.Sprintf("%s (%d %s)", names[i], counts[i], shapes)
+	}
+	return entries
+}
+
+// Draws the text in black on a white box, with its top-left corner at the point.
+func drawLabel(canvas draw.Image, text string, x, y int) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil()
+	draw.Draw(canvas, image.Rect(x, y, x+width+2, y+face.Height), image.White, image.Point{}, draw.Src)
+
+	drawer := font.Drawer{
+		Dst:  canvas,
+		Src:  image.Black,
+		Face: face,
+		Dot:  fixed.P(x+1, y+face.Ascent),
+	}
+	drawer.DrawString(text)
+}
+
+// Draws a swatch of the color, with the label next to it.
+func drawLegendRow(canvas draw.Image, c color.Color, label string, x, y int) {
+	draw.Draw(canvas, image.Rect(x, y, x+12, y+12), image.NewUniform(c), image.Point{}, draw.Src)
+	drawLabel(canvas, label, x+16, y)
+}
+
+// Draws a small square on each vertex of the paths of the shape.
+func drawVertexMarkers(canvas draw.Image, shape ShapeData, paths []Path, c color.Color) {
+	for _, path := range paths {
+		for _, v := range path {
+			x, y := shape.CornerX+int(v.X), shape.CornerY+int(v.Y)
+			marker := image.Rect(x-RENDER_VERTEX_SIZE/2, y-RENDER_VERTEX_SIZE/2, x+RENDER_VERTEX_SIZE/2+1, y+RENDER_VERTEX_SIZE/2+1)
+			draw.Draw(canvas, marker, image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// Draws the paths of the shape as lines of the given width.
+func strokePaths(canvas draw.Image, shape ShapeData, paths []Path, closed bool, width float64, c color.Color) {
+	bounds := canvas.Bounds()
+	r := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	for _, path := range paths {
+		for i := range path {
+			if i == len(path)-1 && !closed {
+				break
+			}
+			a, b := path[i], path[(i+1)%len(path)]
+			strokeSegment(r,
+				float64(shape.CornerX)+float64(a.X), float64(shape.CornerY)+float64(a.Y),
+				float64(shape.CornerX)+float64(b.X), float64(shape.CornerY)+float64(b.Y),
+				width)
+		}
+	}
+	r.Draw(canvas, bounds, image.NewUniform(c), image.Point{})
+}
+
+// Adds a rectangle around the segment, extended by half the width at both ends so segments join up.
+// Every rectangle winds the same way, so where they overlap they add up instead of cancelling out.
+func strokeSegment(r *vector.Rasterizer, x0, y0, x1, y1, width float64) {
+	// pixel centers are at half-pixel offsets
+	x0, y0, x1, y1 = x0+0.5, y0+0.5, x1+0.5, y1+0.5
+
+	dx, dy := x1-x0, y1-y0
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		dx, dy, length = 1, 0, 1
+	}
+	// along the segment, and across it
+	ax, ay := dx/length*width/2, dy/length*width/2
+	nx, ny := -ay, ax
+
+	r.MoveTo(float32(x0-ax+nx), float32(y0-ay+ny))
+	r.LineTo(float32(x1+ax+nx), float32(y1+ay+ny))
+	r.LineTo(float32(x1+ax-nx), float32(y1+ay-ny))
+	r.LineTo(float32(x0-ax-nx), float32(y0-ay-ny))
+	r.ClosePath()
+}
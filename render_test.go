@@ -0,0 +1,69 @@
+package boardshapes
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	// a deserialized image, with bounds at the origin
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := range 10 {
+		for x := range 10 {
+			img.SetNRGBA(x, y, red)
+		}
+	}
+	data := &BoardshapesData{
+		Shapes: []ShapeData{
+			{Number: 0, CornerX: 5, CornerY: 5, Path: Path{{0, 0}, {9, 0}, {9, 9}, {0, 9}}, Color: red, ColorName: "Red", Image: img},
+			{Number: 1, CornerX: 30, CornerY: 10, Path: Path{{0, 0}, {20, 0}, {20, 20}, {0, 20}}, Color: Black},
+		},
+		Metadata: &Metadata{ImageWidth: 60, ImageHeight: 40, Scale: 1},
+	}
+
+	canvas := Render(data, RenderOptions{Background: color.White})
+	if want := image.Rect(0, 0, 60, 40); canvas.Bounds() != want {
+		t.Errorf("Render() bounds = %v, want %v", canvas.Bounds(), want)
+	}
+	if c := canvas.NRGBAAt(7, 7); c != red {
+		t.Errorf("Render() color in the shape's image = %v, want %v", c, red)
+	}
+	if c := canvas.NRGBAAt(40, 20); c != Black {
+		t.Errorf("Render() color in the shape without an image = %v, want %v", c, Black)
+	}
+	if c := canvas.NRGBAAt(2, 2); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("Render() background color = %v, want white", c)
+	}
+
+	annotated := Render(data, RenderOptions{Outlines: true, Vertices: true, Legend: true})
+	if annotated.Bounds().Dy() <= 40 {
+		t.Errorf("Render() with a legend bounds = %v, want taller than the shapes", annotated.Bounds())
+	}
+	if c := annotated.NRGBAAt(50, 20); c != DefaultOutlineColor {
+		t.Errorf("Render() outline color = %v, want %v", c, DefaultOutlineColor)
+	}
+	if c := annotated.NRGBAAt(14, 14); c != DefaultOutlineColor {
+		t.Errorf("Render() vertex marker color = %v, want %v", c, DefaultOutlineColor)
+	}
+}
+
+func TestLegendEntries(t *testing.T) {
+	data := &BoardshapesData{Shapes: []ShapeData{
+		{Color: Black, ColorName: "Black"},
+		{Color: color.NRGBA{G: 255, A: 255}},
+		{Color: Black, ColorName: "Black"},
+	}}
+
+	entries := legendEntries(data)
+	want := []string{"Black (2 shapes)", "#00ff00 (1 shape)"}
+	if len(entries) != len(want) {
+		t.Fatalf("legendEntries() = %d entries, want %d", len(entries), len(want))
+	}
+	for i := range want {
+		if entries[i].label != want[i] {
+			t.Errorf("legendEntries()[%d] label = %q, want %q", i, entries[i].label, want[i])
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package serialization
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+var ErrInvalidGeoJson = errors.New("geojson: invalid or unsupported geojson")
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Version  string           `json:"version,omitempty"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   GeoJSONGeometry   `json:"geometry"`
+	Properties GeoJSONProperties `json:"properties"`
+}
+
+// A Polygon (with coordinates [][][2]float64) or a LineString (with coordinates [][2]float64).
+type GeoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type GeoJSONProperties struct {
+	Number      *int        `json:"number,omitempty"`
+	Color       color.NRGBA `json:"color"`
+	ColorString string      `json:"colorString"`
+	CornerX     *int        `json:"cornerX,omitempty"`
+	CornerY     *int        `json:"cornerY,omitempty"`
+}
+
+// Writes the shapes as a GeoJSON FeatureCollection, with a Polygon feature for each shape (or a LineString for
+// open shapes). Coordinates are where the shapes are in the image, so the y axis points down, which also means
+// that outlines run counter-clockwise and holes clockwise as GeoJSON expects, if the y axis were pointing up.
+func GeoJsonSerialize(w io.Writer, data *main.BoardshapesData) error {
+	collection := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Version:  data.Version,
+		Features: make([]GeoJSONFeature, len(data.Shapes)),
+	}
+
+	for i, shape := range data.Shapes {
+		ring := func(path main.Path, closed bool) [][2]float64 {
+			coordinates := make([][2]float64, 0, len(path)+1)
+			for _, v := range path {
+				coordinates = append(coordinates, [2]float64{float64(int(v.X) + shape.CornerX), float64(int(v.Y) + shape.CornerY)})
+			}
+			if closed && len(path) > 0 {
+				coordinates = append(coordinates, coordinates[0])
+			}
+			return coordinates
+		}
+
+		var geometry GeoJSONGeometry
+		var coordinates any
+		if shape.Open {
+			geometry.Type = "LineString"
+			coordinates = ring(shape.Path, false)
+		} else {
+			geometry.Type = "Polygon"
+			rings := [][][2]float64{ring(shape.Path, true)}
+			for _, hole := range shape.Holes {
+				rings = append(rings, ring(hole, true))
+			}
+			coordinates = rings
+		}
+		var err error
+		if geometry.Coordinates, err = json.Marshal(coordinates); err != nil {
+			return err
+		}
+
+		number, cornerX, cornerY := shape.Number, shape.CornerX, shape.CornerY
+		collection.Features[i] = GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: GeoJSONProperties{
+				Number:      &number,
+				Color:       main.GetNRGBA(shape.Color),
+				ColorString: shape.ColorName,
+				CornerX:     &cornerX,
+				CornerY:     &cornerY,
+			},
+		}
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
+
+// Reads shapes from a GeoJSON FeatureCollection of Polygon and LineString features, such as one written by
+// [GeoJsonSerialize]. The first ring of each polygon is the shape's outline, and the rest are its holes.
+// Shape images are recreated by filling in the shapes with their colors.
+// Features with coordinates left of or above the image (below 0) are invalid.
+func GeoJsonDeserialize(r io.Reader) (*main.BoardshapesData, error) {
+	var collection GeoJSONFeatureCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("%w: expected a FeatureCollection, not %q", ErrInvalidGeoJson, collection.Type)
+	}
+
+	data := &main.BoardshapesData{
+		Version: collection.Version,
+		Shapes:  make([]main.ShapeData, 0, len(collection.Features)),
+	}
+	if data.Version == "" {
+		data.Version = main.VERSION
+	}
+
+	for i, feature := range collection.Features {
+		var rings [][][2]float64
+		open := false
+		switch feature.Geometry.Type {
+		case "Polygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidGeoJson, err)
+			}
+			for j, ring := range rings {
+				// rings end where they start
+				if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+					rings[j] = ring[:len(ring)-1]
+				}
+			}
+		case "LineString":
+			var line [][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &line); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidGeoJson, err)
+			}
+			rings, open = [][][2]float64{line}, true
+		default:
+			return nil, fmt.Errorf("%w: unsupported geometry type %q", ErrInvalidGeoJson, feature.Geometry.Type)
+		}
+		if len(rings) == 0 || len(rings[0]) == 0 {
+			continue
+		}
+
+		// the corner can be further out than the rings, but not further in
+		properties := feature.Properties
+		cornerX, cornerY := math.Inf(1), math.Inf(1)
+		for _, ring := range rings {
+			for _, c := range ring {
+				cornerX, cornerY = min(cornerX, c[0]), min(cornerY, c[1])
+			}
+		}
+		if properties.CornerX != nil {
+			cornerX = min(cornerX, float64(*properties.CornerX))
+		}
+		if properties.CornerY != nil {
+			cornerY = min(cornerY, float64(*properties.CornerY))
+		}
+		cornerX, cornerY = math.Floor(cornerX), math.Floor(cornerY)
+		if cornerX < 0 || cornerY < 0 {
+			return nil, fmt.Errorf("%w: feature %d is left of or above the image", ErrInvalidGeoJson, i)
+		}
+
+		paths := make([]main.Path, len(rings))
+		for j, ring := range rings {
+			paths[j] = make(main.Path, len(ring))
+			for k, c := range ring {
+				x, y := math.Round(c[0]-cornerX), math.Round(c[1]-cornerY)
+				if x > math.MaxUint16 || y > math.MaxUint16 {
+					return nil, fmt.Errorf("%w: feature %d is too large", ErrInvalidGeoJson, i)
+				}
+				paths[j][k] = main.Vertex{X: uint16(x), Y: uint16(y)}
+			}
+		}
+
+		number := i
+		if properties.Number != nil {
+			number = *properties.Number
+		}
+
+		shape := main.ShapeData{
+			Number:    number,
+			Color:     properties.Color,
+			ColorName: properties.ColorString,
+			CornerX:   int(cornerX),
+			CornerY:   int(cornerY),
+			Path:      paths[0],
+			Open:      open,
+		}
+		if len(paths) > 1 {
+			shape.Holes = paths[1:]
+		}
+		shape.Image = rasterizeShape(shape)
+		data.Shapes = append(data.Shapes, shape)
+	}
+
+	return data, nil
+}
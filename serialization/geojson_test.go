@@ -0,0 +1,109 @@
+package serialization
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+func TestGeoJsonSerialization(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{ExtractCenterlines: true})
+	w := &bytes.Buffer{}
+	if err := GeoJsonSerialize(w, data); err != nil {
+		t.Fatalf("GeoJsonSerialize() error = %v", err)
+	}
+
+	result, err := GeoJsonDeserialize(w)
+	if err != nil {
+		t.Fatalf("GeoJsonDeserialize() error = %v", err)
+	}
+	if len(result.Shapes) != len(data.Shapes) {
+		t.Fatalf("GeoJsonDeserialize() returned %d shapes, want %d", len(result.Shapes), len(data.Shapes))
+	}
+	// images aren't kept, so compare everything else
+	for i, got := range result.Shapes {
+		want := data.Shapes[i]
+		if got.Number != want.Number || got.Color != want.Color || got.ColorName != want.ColorName ||
+			got.CornerX != want.CornerX || got.CornerY != want.CornerY || got.Open != want.Open ||
+			!slices.Equal(got.Path, want.Path) || !slices.EqualFunc(got.Holes, want.Holes, slices.Equal) {
+			t.Errorf("GeoJsonDeserialize() shape %d doesn't match the original", i)
+		}
+	}
+}
+
+func TestGeoJsonDeserialize(t *testing.T) {
+	// the kind of GeoJSON a GIS tool would write, without any of the extra properties
+	const geoJson = `{
+		"type": "FeatureCollection",
+		"features": [
+			{
+				"type": "Feature",
+				"geometry": {
+					"type": "Polygon",
+					"coordinates": [
+						[[10.2, 20], [50, 20], [50, 60], [10, 60], [10.2, 20]],
+						[[20, 30], [20, 40], [30, 40], [20, 30]]
+					]
+				},
+				"properties": {"colorString": "Red", "color": {"R": 255, "G": 0, "B": 0, "A": 255}}
+			},
+			{
+				"type": "Feature",
+				"geometry": {"type": "LineString", "coordinates": [[0, 0], [5, 5]]},
+				"properties": {}
+			}
+		]
+	}`
+
+	data, err := GeoJsonDeserialize(strings.NewReader(geoJson))
+	if err != nil {
+		t.Fatalf("GeoJsonDeserialize() error = %v", err)
+	}
+	if len(data.Shapes) != 2 {
+		t.Fatalf("GeoJsonDeserialize() returned %d shapes, want 2", len(data.Shapes))
+	}
+
+	polygon := data.Shapes[0]
+	wantPath := main.Path{{X: 0, Y: 0}, {X: 40, Y: 0}, {X: 40, Y: 40}, {X: 0, Y: 40}}
+	if polygon.CornerX != 10 || polygon.CornerY != 20 || !slices.Equal(polygon.Path, wantPath) {
+		t.Errorf("GeoJsonDeserialize() polygon at (%d, %d) with path %v, want (10, 20) with %v",
+			polygon.CornerX, polygon.CornerY, polygon.Path, wantPath)
+	}
+	if len(polygon.Holes) != 1 || len(polygon.Holes[0]) != 3 {
+		t.Errorf("GeoJsonDeserialize() polygon holes = %v, want one triangle", polygon.Holes)
+	}
+	if polygon.Color != (color.NRGBA{255, 0, 0, 255}) || polygon.ColorName != "Red" {
+		t.Errorf("GeoJsonDeserialize() polygon color = %v %q, want red", polygon.Color, polygon.ColorName)
+	}
+
+	line := data.Shapes[1]
+	if !line.Open || line.Number != 1 || len(line.Path) != 2 {
+		t.Errorf("GeoJsonDeserialize() line = %+v, want an open shape numbered 1 with 2 vertices", line)
+	}
+
+	// a corner further in than the coordinates is moved out to them
+	data, err = GeoJsonDeserialize(strings.NewReader(`{"type": "FeatureCollection", "features": [{"type": "Feature",
+		"geometry": {"type": "LineString", "coordinates": [[4, 5], [10, 10]]}, "properties": {"cornerX": 8, "cornerY": 2}}]}`))
+	if err != nil {
+		t.Fatalf("GeoJsonDeserialize() error = %v", err)
+	}
+	if shape := data.Shapes[0]; shape.CornerX != 4 || shape.CornerY != 2 || shape.Path[0] != (main.Vertex{X: 0, Y: 3}) {
+		t.Errorf("GeoJsonDeserialize() line at (%d, %d) with path %v, want (4, 2) starting at {0 3}",
+			shape.CornerX, shape.CornerY, shape.Path)
+	}
+
+	negative := `{"type": "FeatureCollection", "features": [{"type": "Feature",
+		"geometry": {"type": "LineString", "coordinates": [[-4, 5], [10, 10]]}, "properties": {}}]}`
+	if _, err := GeoJsonDeserialize(strings.NewReader(negative)); !errors.Is(err, ErrInvalidGeoJson) {
+		t.Errorf("GeoJsonDeserialize() of negative coordinates error = %v, want %v", err, ErrInvalidGeoJson)
+	}
+
+	if _, err := GeoJsonDeserialize(strings.NewReader(`{"type": "Feature"}`)); err == nil {
+		t.Errorf("GeoJsonDeserialize() of a single feature error = nil, want an error")
+	}
+}
@@ -1,27 +1,61 @@
 package serialization
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"image/color"
 	"image/png"
 	"io"
+	"maps"
+	"math"
+	"slices"
 	"strings"
 
 	main "github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization/shared"
 	v0_1 "github.com/boardshapes/boardshapes/serialization/v0.1"
+	v0_2 "github.com/boardshapes/boardshapes/serialization/v0.2"
 )
 
 const (
-	CHUNK_VERSION        = 0
-	CHUNK_COLOR_TABLE    = 2
-	CHUNK_SHAPE_GEOMETRY = 8
-	CHUNK_SHAPE_COLOR    = 9
-	CHUNK_SHAPE_IMAGE    = 10
-	CHUNK_SHAPE_MASK     = 11
+	CHUNK_VERSION         = 0
+	CHUNK_CHECKSUM        = 1
+	CHUNK_COLOR_TABLE     = 2
+	CHUNK_METADATA        = 3
+	CHUNK_COMPRESSION     = 4
+	CHUNK_SHAPE_GEOMETRY  = 8
+	CHUNK_SHAPE_COLOR     = 9
+	CHUNK_SHAPE_IMAGE     = 10
+	CHUNK_SHAPE_MASK      = 11
+	CHUNK_SHAPE_HOLES     = 12
+	CHUNK_SHAPE_FLAGS     = 13
+	CHUNK_SHAPE_PRIMITIVE = 14
+	CHUNK_SHAPE_CURVES    = 15
+	// Like CHUNK_SHAPE_GEOMETRY and CHUNK_SHAPE_HOLES, but with each vertex stored as the difference
+	// from the one before it.
+	CHUNK_SHAPE_DELTA_GEOMETRY = 16
+	CHUNK_SHAPE_DELTA_HOLES    = 17
+)
+
+// Every chunk but the version chunk starts with its ID, followed by the length of the rest of the chunk
+// as a big-endian 32-bit unsigned integer.
+const CHUNK_HEADER_SIZE = 5
+
+const (
+	SHAPE_FLAG_OPEN = 0b00000001
+)
+
+const (
+	METADATA_FLAG_METADATA  = 0b00000001
+	METADATA_FLAG_TRANSFORM = 0b00000010
+)
+
+const (
+	COMPRESSION_NONE  = 0
+	COMPRESSION_FLATE = 1
 )
 
 type BinaryDeserializeFunc func(r io.Reader, options map[string]any) (*main.BoardshapesData, error)
@@ -37,17 +71,33 @@ func (e ErrUnknownChunkType) Error() string {
 var ErrVersionNotFound = errors.New("version of the data could not be found, cannot deserialize with backwards-compatible deserializer")
 var ErrInvalidVersion = errors.New("version of the data is invalid, cannot deserialize with backwards-compatible deserializer")
 var ErrIncompatibleVersion = errors.New("version of the data is incompatible with the backwards-compatible deserializer, cannot deserialize")
+var ErrChecksumMismatch = shared.ErrChecksumMismatch
+var ErrChecksumMissing = shared.ErrChecksumMissing
+var ErrDecompressedTooLarge = shared.ErrDecompressedTooLarge
+var ErrImageTooLarge = shared.ErrImageTooLarge
+
+const DEFAULT_MAX_IMAGE_PIXELS = shared.DEFAULT_MAX_IMAGE_PIXELS
 
 var binaryDeserializers = map[string]BinaryDeserializeFunc{
 	"0.1": v0_1.BinaryDeserialize,
+	"0.2": v0_2.BinaryDeserialize,
 }
 
 var jsonDeserializers = map[string]JsonDeserializeFunc{
 	"0.1": v0_1.JsonDeserialize,
+	"0.2": v0_2.JsonDeserialize,
 }
 
 type SerializationOptions struct {
 	UseMasks bool
+	// Ends binary data with a checksum chunk, so deserializers can tell if it was corrupted or cut short.
+	Checksum bool
+	// Compresses everything in binary data after the version chunk, such as with [COMPRESSION_FLATE].
+	// Deserializers detect it on their own.
+	Compression int
+	// Stores paths and holes with each vertex as the difference from the one before it, which takes up much less space
+	// for paths that weren't optimized, since their vertices are only a pixel or so apart.
+	DeltaGeometry bool
 }
 
 var DefaultOptions = SerializationOptions{
@@ -55,46 +105,104 @@ var DefaultOptions = SerializationOptions{
 }
 
 func BinarySerialize(w io.Writer, data *main.BoardshapesData, options *SerializationOptions) error {
-	if options == nil {
-		options = &DefaultOptions
+	encoder := NewEncoder(w, options)
+	if err := encoder.WriteHeader(data); err != nil {
+		return err
 	}
+	for _, shape := range data.Shapes {
+		if err := encoder.WriteShape(shape); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
 
-	var buf bytes.Buffer
-
-	// write version chunk
-	chunk := append([]byte{CHUNK_VERSION}, append([]byte(main.VERSION), 0)...)
-	_, err := buf.Write(chunk)
-	if err != nil {
-		return err
+// Appends the metadata chunk, if the data has metadata or a transform.
+func appendMetadataChunk(chunk []byte, data *main.BoardshapesData) []byte {
+	if data.Metadata == nil && data.Transform == nil {
+		return chunk
 	}
+	start := len(chunk)
+	chunk = append(chunk, CHUNK_METADATA, 0, 0, 0, 0)
 
-	// get all colors
-	colors := make(map[color.Color]string)
-	for _, shape := range data.Shapes {
-		if shape.ColorName != "" {
-			colors[shape.Color] = shape.ColorName
+	flags := byte(0)
+	if data.Metadata != nil {
+		flags |= METADATA_FLAG_METADATA
+	}
+	if data.Transform != nil {
+		flags |= METADATA_FLAG_TRANSFORM
+	}
+	chunk = append(chunk, flags)
+
+	if metadata := data.Metadata; metadata != nil {
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(metadata.ImageWidth))
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(metadata.ImageHeight))
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(metadata.SourceWidth))
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(metadata.SourceHeight))
+		chunk = binary.BigEndian.AppendUint64(chunk, math.Float64bits(metadata.Scale))
+		createdAt := int64(0)
+		if !metadata.CreatedAt.IsZero() {
+			createdAt = metadata.CreatedAt.UnixNano()
+		}
+		chunk = binary.BigEndian.AppendUint64(chunk, uint64(createdAt))
+		chunk = appendStringMap(chunk, metadata.Options)
+		chunk = appendStringMap(chunk, metadata.Tags)
+	}
+	if data.Transform != nil {
+		for _, f := range data.Transform {
+			chunk = binary.BigEndian.AppendUint64(chunk, math.Float64bits(f))
 		}
 	}
 
-	// write color table chunk
-	chunk = []byte{CHUNK_COLOR_TABLE}
-	chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(colors)))
+	setChunkLengths(chunk, []int{start})
+	return chunk
+}
 
-	for color, name := range colors {
-		nrgba := main.GetNRGBA(color)
-		chunk = append(chunk, nrgba.R, nrgba.G, nrgba.B, nrgba.A)
-		chunk = append(chunk, name...)
+// Appends the number of entries, then each key and value as null-terminated strings, ordered by key.
+func appendStringMap(chunk []byte, m map[string]string) []byte {
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(m)))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		chunk = append(chunk, key...)
+		chunk = append(chunk, 0)
+		chunk = append(chunk, m[key]...)
 		chunk = append(chunk, 0)
 	}
-	_, err = buf.Write(chunk)
-	if err != nil {
-		return err
+	return chunk
+}
+
+// Appends the number of vertices as a varint, then each vertex as the (zigzag varint) difference
+// from the one before it. The first vertex is relative to (0, 0), which is the shape's corner.
+func appendDeltaVertices(chunk []byte, path []main.Vertex) []byte {
+	chunk = binary.AppendUvarint(chunk, uint64(len(path)))
+	prevX, prevY := 0, 0
+	for _, vert := range path {
+		chunk = binary.AppendVarint(chunk, int64(int(vert.X)-prevX))
+		chunk = binary.AppendVarint(chunk, int64(int(vert.Y)-prevY))
+		prevX, prevY = int(vert.X), int(vert.Y)
 	}
+	return chunk
+}
 
-	// write shapes chunks
-	for _, shape := range data.Shapes {
+// Appends all the chunks of a shape.
+func appendShapeChunks(chunk []byte, shape main.ShapeData, options *SerializationOptions) ([]byte, error) {
+	// chunk lengths are filled in once all the chunks have been appended
+	starts := make([]int, 0)
+	beginChunk := func(id byte) {
+		starts = append(starts, len(chunk))
+		chunk = append(chunk, id, 0, 0, 0, 0)
+	}
+
+	if options.DeltaGeometry {
+		// shape delta geometry chunk
+		beginChunk(CHUNK_SHAPE_DELTA_GEOMETRY)
+
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+		chunk = binary.BigEndian.AppendUint16(chunk, uint16(shape.CornerX))
+		chunk = binary.BigEndian.AppendUint16(chunk, uint16(shape.CornerY))
+		chunk = appendDeltaVertices(chunk, shape.Path)
+	} else {
 		// shape geometry chunk
-		chunk := []byte{CHUNK_SHAPE_GEOMETRY}
+		beginChunk(CHUNK_SHAPE_GEOMETRY)
 
 		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
 		chunk = binary.BigEndian.AppendUint16(chunk, uint16(shape.CornerX))
@@ -105,124 +213,247 @@ func BinarySerialize(w io.Writer, data *main.BoardshapesData, options *Serializa
 			chunk = binary.BigEndian.AppendUint16(chunk, uint16(vert.X))
 			chunk = binary.BigEndian.AppendUint16(chunk, uint16(vert.Y))
 		}
+	}
 
-		// shape color chunk
-		nrgba := main.GetNRGBA(shape.Color)
-		chunk = append(chunk, CHUNK_SHAPE_COLOR)
+	if len(shape.Holes) > 0 && options.DeltaGeometry {
+		// shape delta holes chunk
+		beginChunk(CHUNK_SHAPE_DELTA_HOLES)
 		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
-		chunk = append(chunk, nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+		chunk = binary.AppendUvarint(chunk, uint64(len(shape.Holes)))
+		for _, hole := range shape.Holes {
+			chunk = appendDeltaVertices(chunk, hole)
+		}
+	} else if len(shape.Holes) > 0 {
+		// shape holes chunk
+		beginChunk(CHUNK_SHAPE_HOLES)
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(shape.Holes)))
 
-		if shape.Image != nil && shape.Image.Bounds().Dx() > 0 && shape.Image.Bounds().Dy() > 0 {
-			if options.UseMasks {
-				// shape mask chunk
-				chunk = append(chunk, CHUNK_SHAPE_MASK)
-				chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+		for _, hole := range shape.Holes {
+			chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(hole)))
+			for _, vert := range hole {
+				chunk = binary.BigEndian.AppendUint16(chunk, uint16(vert.X))
+				chunk = binary.BigEndian.AppendUint16(chunk, uint16(vert.Y))
+			}
+		}
+	}
 
-				img := shape.Image
-				bds := img.Bounds()
+	if shape.Open {
+		// shape flags chunk
+		beginChunk(CHUNK_SHAPE_FLAGS)
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+		chunk = append(chunk, SHAPE_FLAG_OPEN)
+	}
 
-				chunk = binary.BigEndian.AppendUint16(chunk, uint16(bds.Dx()))
+	if shape.Primitive != nil {
+		// shape primitive chunk
+		primitive := shape.Primitive
+		beginChunk(CHUNK_SHAPE_PRIMITIVE)
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+		chunk = append(chunk, byte(primitive.Kind))
+		for _, f := range []float64{
+			primitive.Confidence,
+			primitive.CenterX, primitive.CenterY,
+			primitive.RadiusX, primitive.RadiusY,
+			primitive.Angle,
+		} {
+			chunk = binary.BigEndian.AppendUint64(chunk, math.Float64bits(f))
+		}
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(primitive.Corners)))
+		for _, vert := range primitive.Corners {
+			chunk = binary.BigEndian.AppendUint16(chunk, uint16(vert.X))
+			chunk = binary.BigEndian.AppendUint16(chunk, uint16(vert.Y))
+		}
+	}
 
-				_, _, _, a := img.At(bds.Min.X, bds.Min.Y).RGBA()
-				prevFilled := a > 0
-				if prevFilled {
-					chunk = append(chunk, 1)
-				} else {
-					chunk = append(chunk, 0)
-				}
+	if shape.Curves != nil {
+		// shape curves chunk
+		beginChunk(CHUNK_SHAPE_CURVES)
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+		chunk = appendCurves(chunk, shape.Curves)
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(shape.HoleCurves)))
+		for _, curves := range shape.HoleCurves {
+			chunk = appendCurves(chunk, curves)
+		}
+	}
 
-				runLength := 0
-
-				for y := bds.Min.Y; y < bds.Max.Y; y++ {
-					for x := bds.Min.X; x < bds.Max.X; x++ {
-						_, _, _, a := img.At(x, y).RGBA()
-						filled := a > 0
-						if prevFilled == filled {
-							runLength++
-						} else {
-							chunk = binary.AppendUvarint(chunk, uint64(runLength))
-							runLength = 1
-							prevFilled = filled
-						}
-					}
-				}
+	// shape color chunk
+	nrgba := main.GetNRGBA(shape.Color)
+	beginChunk(CHUNK_SHAPE_COLOR)
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+	chunk = append(chunk, nrgba.R, nrgba.G, nrgba.B, nrgba.A)
 
-				chunk = binary.AppendUvarint(chunk, uint64(runLength))
-				chunk = append(chunk, 0)
+	if shape.Image != nil && shape.Image.Bounds().Dx() > 0 && shape.Image.Bounds().Dy() > 0 {
+		if options.UseMasks {
+			// shape mask chunk
+			beginChunk(CHUNK_SHAPE_MASK)
+			chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+
+			img := shape.Image
+			bds := img.Bounds()
+
+			chunk = binary.BigEndian.AppendUint16(chunk, uint16(bds.Dx()))
+
+			_, _, _, a := img.At(bds.Min.X, bds.Min.Y).RGBA()
+			prevFilled := a > 0
+			if prevFilled {
+				chunk = append(chunk, 1)
 			} else {
-				// shape image chunk
-				chunk = append(chunk, CHUNK_SHAPE_IMAGE)
-				chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
+				chunk = append(chunk, 0)
+			}
 
-				var pngBuf bytes.Buffer
-				png.Encode(&pngBuf, shape.Image)
+			runLength := 0
+
+			for y := bds.Min.Y; y < bds.Max.Y; y++ {
+				for x := bds.Min.X; x < bds.Max.X; x++ {
+					_, _, _, a := img.At(x, y).RGBA()
+					filled := a > 0
+					if prevFilled == filled {
+						runLength++
+					} else {
+						chunk = binary.AppendUvarint(chunk, uint64(runLength))
+						runLength = 1
+						prevFilled = filled
+					}
+				}
+			}
+
+			chunk = binary.AppendUvarint(chunk, uint64(runLength))
+			chunk = append(chunk, 0)
+		} else {
+			// shape image chunk
+			beginChunk(CHUNK_SHAPE_IMAGE)
+			chunk = binary.BigEndian.AppendUint32(chunk, uint32(shape.Number))
 
-				chunk = binary.BigEndian.AppendUint32(chunk, uint32(pngBuf.Len()))
-				chunk = append(chunk, pngBuf.Bytes()...)
+			var pngBuf bytes.Buffer
+			if err := png.Encode(&pngBuf, shape.Image); err != nil {
+				return nil, err
 			}
-		}
 
-		_, err = buf.Write(chunk)
-		if err != nil {
-			return err
+			chunk = binary.BigEndian.AppendUint32(chunk, uint32(pngBuf.Len()))
+			chunk = append(chunk, pngBuf.Bytes()...)
 		}
 	}
 
-	_, err = buf.WriteTo(w)
+	setChunkLengths(chunk, starts)
+	return chunk, nil
+}
 
-	return err
+// Fills in the lengths of chunks that were appended one after another, given where each one starts.
+func setChunkLengths(chunk []byte, starts []int) {
+	for i, start := range starts {
+		end := len(chunk)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		binary.BigEndian.PutUint32(chunk[start+1:], uint32(end-start-CHUNK_HEADER_SIZE))
+	}
 }
 
-func BinaryDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData, error) {
-	var buf bytes.Buffer
-	_, err := buf.ReadFrom(r)
-	if err != nil {
-		return nil, err
+// Appends the number of curves, then where the first one starts,
+// then the control points and end of each one (which is where the next one starts).
+func appendCurves(chunk []byte, curves []main.CubicBezier) []byte {
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(curves)))
+	if len(curves) == 0 {
+		return chunk
+	}
+	points := []main.CurvePoint{curves[0].Start}
+	for _, curve := range curves {
+		points = append(points, curve.Control1, curve.Control2, curve.End)
 	}
+	for _, p := range points {
+		chunk = binary.BigEndian.AppendUint32(chunk, math.Float32bits(p.X))
+		chunk = binary.BigEndian.AppendUint32(chunk, math.Float32bits(p.Y))
+	}
+	return chunk
+}
 
-	bufBytes := buf.Bytes()
-	if bufBytes[0] != 0 {
-		return nil, ErrVersionNotFound
+// Flattens the curves into where the first one starts, then the control points and end of each one.
+func flattenCurves(curves []main.CubicBezier) []float32 {
+	if len(curves) == 0 {
+		return nil
+	}
+	flat := []float32{curves[0].Start.X, curves[0].Start.Y}
+	for _, curve := range curves {
+		flat = append(flat,
+			curve.Control1.X, curve.Control1.Y,
+			curve.Control2.X, curve.Control2.Y,
+			curve.End.X, curve.End.Y)
 	}
+	return flat
+}
 
-	nullIndex := bytes.IndexByte(bufBytes[1:], 0)
-	if nullIndex == -1 {
-		return nil, ErrVersionNotFound
+// Returns the entries of the data's palette, followed by any other named shape colors that aren't in it,
+// which readers name shapes with but leave out of the palette.
+func colorTable(data *main.BoardshapesData) []main.PaletteEntry {
+	colors := slices.Clone(data.Palette.Entries)
+	for _, shape := range data.Shapes {
+		if shape.ColorName == "" || shape.Color == nil {
+			continue
+		}
+		nrgba := main.GetNRGBA(shape.Color)
+		if !slices.ContainsFunc(colors, func(e main.PaletteEntry) bool { return e.Color == nrgba }) {
+			colors = append(colors, main.PaletteEntry{Name: shape.ColorName, Color: nrgba})
+		}
 	}
-	version := string(bufBytes[1 : nullIndex+1])
+	return colors
+}
 
-	vnums := strings.Split(version, ".")
-	if len(vnums) < 2 {
-		return nil, ErrVersionNotFound
+// Options can include "baseImage" (image.Image), to color shapes stored as masks with the image they came from,
+// "requireChecksum" (bool), to fail on data without a checksum chunk instead of trusting it,
+// "maxDecompressedSize" (int64), to fail on compressed data that's larger than this many bytes once decompressed,
+// and "maxImagePixels" (int), to fail on shape images and masks with more pixels than this
+// instead of [DEFAULT_MAX_IMAGE_PIXELS] (or 0 for no limit).
+func BinaryDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData, error) {
+	br := bufio.NewReader(r)
+	majorMinor, err := peekBinaryVersion(br)
+	if err != nil {
+		return nil, err
 	}
 
-	deserializeFunc, ok := binaryDeserializers[vnums[0]+"."+vnums[1]]
+	deserializeFunc, ok := binaryDeserializers[majorMinor]
 	if !ok {
 		return nil, ErrIncompatibleVersion
 	}
 
-	return deserializeFunc(&buf, options)
-}
-
-type JSONData struct {
-	Version string          `json:"version"`
-	Shapes  []JSONShapeData `json:"shapes"`
+	return deserializeFunc(br, options)
 }
 
-type JSONShapeData struct {
-	Number      int         `json:"number"`
-	CornerX     int         `json:"cornerX"`
-	CornerY     int         `json:"cornerY"`
-	Shape       []uint16    `json:"path"`
-	Color       color.NRGBA `json:"color"`
-	ColorString string      `json:"colorString"`
-	Image       string      `json:"image"`
-}
+// The JSON format's types, which deserializers share.
+type (
+	JSONData         = shared.JSONData
+	JSONMetadata     = shared.JSONMetadata
+	JSONPaletteEntry = shared.JSONPaletteEntry
+	JSONShapeData    = shared.JSONShapeData
+	JSONPrimitive    = shared.JSONPrimitive
+)
 
 func JsonSerialize(w io.Writer, data *main.BoardshapesData) error {
 	jsonData := JSONData{
-		Version: data.Version,
-		Shapes:  make([]JSONShapeData, len(data.Shapes)),
+		Version:          data.Version,
+		PaletteTolerance: data.Palette.Tolerance,
+		Transform:        data.Transform,
+		Shapes:           make([]JSONShapeData, len(data.Shapes)),
+	}
+
+	if metadata := data.Metadata; metadata != nil {
+		jsonData.Metadata = &JSONMetadata{
+			ImageWidth:   metadata.ImageWidth,
+			ImageHeight:  metadata.ImageHeight,
+			SourceWidth:  metadata.SourceWidth,
+			SourceHeight: metadata.SourceHeight,
+			Scale:        metadata.Scale,
+			Options:      metadata.Options,
+			CreatedAt:    metadata.CreatedAt,
+			Tags:         metadata.Tags,
+		}
+	}
+
+	for _, entry := range data.Palette.Entries {
+		jsonData.Palette = append(jsonData.Palette, JSONPaletteEntry{
+			Name:  entry.Name,
+			Color: entry.Color,
+		})
 	}
 
 	for i, shape := range data.Shapes {
@@ -232,6 +463,39 @@ func JsonSerialize(w io.Writer, data *main.BoardshapesData) error {
 			points[j*2+1] = v.Y
 		}
 
+		var holes [][]uint16
+		if len(shape.Holes) > 0 {
+			holes = make([][]uint16, len(shape.Holes))
+			for j, hole := range shape.Holes {
+				holes[j] = make([]uint16, len(hole)*2)
+				for k, v := range hole {
+					holes[j][k*2] = v.X
+					holes[j][k*2+1] = v.Y
+				}
+			}
+		}
+
+		var primitive *JSONPrimitive
+		if shape.Primitive != nil {
+			primitive = &JSONPrimitive{
+				Kind:       shape.Primitive.Kind.String(),
+				Confidence: shape.Primitive.Confidence,
+				CenterX:    shape.Primitive.CenterX,
+				CenterY:    shape.Primitive.CenterY,
+				RadiusX:    shape.Primitive.RadiusX,
+				RadiusY:    shape.Primitive.RadiusY,
+				Angle:      shape.Primitive.Angle,
+			}
+			for _, v := range shape.Primitive.Corners {
+				primitive.Corners = append(primitive.Corners, v.X, v.Y)
+			}
+		}
+
+		var holeCurves [][]float32
+		for _, curves := range shape.HoleCurves {
+			holeCurves = append(holeCurves, flattenCurves(curves))
+		}
+
 		var imgBase64 string
 		if shape.Image != nil {
 			buf := new(bytes.Buffer)
@@ -249,6 +513,11 @@ func JsonSerialize(w io.Writer, data *main.BoardshapesData) error {
 			Color:       main.GetNRGBA(shape.Color),
 			ColorString: shape.ColorName,
 			Image:       imgBase64,
+			Holes:       holes,
+			Open:        shape.Open,
+			Primitive:   primitive,
+			Curves:      flattenCurves(shape.Curves),
+			HoleCurves:  holeCurves,
 		}
 	}
 
@@ -263,6 +532,7 @@ type JSONWithVersion struct {
 	Version string `json:"version"`
 }
 
+// Options can include "maxImagePixels" (int), like for [BinaryDeserialize].
 func JsonDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData, error) {
 	var buf bytes.Buffer
 	_, err := buf.ReadFrom(r)
@@ -2,11 +2,16 @@ package serialization
 
 import (
 	"bytes"
+	"errors"
 	"image"
+	"image/color"
+	"image/draw"
 	_ "image/jpeg"
 	_ "image/png"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
 	main "github.com/boardshapes/boardshapes"
 )
@@ -24,6 +29,107 @@ func loadImage(filepath string) image.Image {
 	return img
 }
 
+// Makes a square shape by hand, for testing the chunks that serialize each part of a shape.
+// Its image is left empty in the middle, like the images of shapes with holes.
+func testShape(number int, c color.NRGBA, name string) main.ShapeData {
+	img := image.NewNRGBA(image.Rect(10*number, 20, 10*number+8, 28))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10*number+2, 22, 10*number+5, 25), image.Transparent, image.Point{}, draw.Src)
+	return main.ShapeData{
+		Number:    number,
+		Color:     c,
+		ColorName: name,
+		CornerX:   10 * number,
+		CornerY:   20,
+		Image:     img,
+		Path:      main.Path{{X: 0, Y: 0}, {X: 8, Y: 0}, {X: 8, Y: 8}, {X: 0, Y: 8}},
+	}
+}
+
+func testData(shapes ...main.ShapeData) *main.BoardshapesData {
+	return &main.BoardshapesData{
+		Version: main.VERSION,
+		Palette: main.Palette{Entries: slices.Clone(main.DefaultPalette.Entries)},
+		Shapes:  shapes,
+	}
+}
+
+func TestShapeChunkSerialization(t *testing.T) {
+	holes := testShape(0, main.Black, "Black")
+	holes.Holes = []main.Path{{{X: 2, Y: 2}, {X: 2, Y: 5}, {X: 5, Y: 5}, {X: 5, Y: 2}}}
+
+	open := testShape(1, main.Blue, "Blue")
+	open.Path = main.Path{{X: 0, Y: 0}, {X: 4, Y: 7}, {X: 8, Y: 0}}
+	open.Open = true
+
+	primitive := testShape(2, main.Red, "Red")
+	primitive.Primitive = &main.Primitive{
+		Kind:       main.PRIMITIVE_RECTANGLE,
+		Confidence: 0.9,
+		CenterX:    4,
+		CenterY:    4,
+		RadiusX:    4,
+		RadiusY:    4,
+		Corners:    []main.Vertex{{X: 0, Y: 0}, {X: 8, Y: 0}, {X: 8, Y: 8}, {X: 0, Y: 8}},
+	}
+
+	curves := testShape(3, main.Green, "Green")
+	curves.Holes = holes.Holes
+	curves.Curves = []main.CubicBezier{
+		{Start: main.CurvePoint{X: 0, Y: 0}, Control1: main.CurvePoint{X: 4, Y: -1.5}, Control2: main.CurvePoint{X: 8.5, Y: 4}, End: main.CurvePoint{X: 8, Y: 8}},
+		{Start: main.CurvePoint{X: 8, Y: 8}, Control1: main.CurvePoint{X: 4, Y: 9.25}, Control2: main.CurvePoint{X: -0.5, Y: 4}, End: main.CurvePoint{X: 0, Y: 0}},
+	}
+	curves.HoleCurves = [][]main.CubicBezier{{
+		{Start: main.CurvePoint{X: 2, Y: 2}, Control1: main.CurvePoint{X: 2, Y: 5}, Control2: main.CurvePoint{X: 5, Y: 5}, End: main.CurvePoint{X: 2, Y: 2}},
+	}}
+
+	// a shape color that isn't in the palette stays out of it
+	palette := testData(testShape(0, main.Cyan, "Cyan"), testShape(1, color.NRGBA{255, 128, 0, 255}, "Orange"))
+	palette.Palette.Entries = slices.Clone(main.ExtendedPalette.Entries)
+	palette.Palette.Tolerance = 12.5
+
+	tests := []struct {
+		name string
+		data *main.BoardshapesData
+	}{
+		{name: "holes", data: testData(holes)},
+		{name: "open", data: testData(open)},
+		{name: "primitive", data: testData(primitive)},
+		{name: "curves", data: testData(curves)},
+		{name: "palette", data: palette},
+		{name: "all", data: testData(holes, open, primitive, curves)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, options := range []SerializationOptions{DefaultOptions, {}, {Compression: COMPRESSION_FLATE}} {
+				w := &bytes.Buffer{}
+				if err := BinarySerialize(w, tt.data, &options); err != nil {
+					t.Fatalf("BinarySerialize() with %+v error = %v", options, err)
+				}
+				result, err := BinaryDeserialize(w, nil)
+				if err != nil {
+					t.Fatalf("BinaryDeserialize() with %+v error = %v", options, err)
+				}
+				if equal, reason := tt.data.Equal(*result); !equal {
+					t.Errorf("Binary data mismatch with %+v: %v", options, reason)
+				}
+			}
+
+			w := &bytes.Buffer{}
+			if err := JsonSerialize(w, tt.data); err != nil {
+				t.Fatalf("JsonSerialize() error = %v", err)
+			}
+			result, err := JsonDeserialize(w, nil)
+			if err != nil {
+				t.Fatalf("JsonDeserialize() error = %v", err)
+			}
+			if equal, reason := tt.data.Equal(*result); !equal {
+				t.Errorf("JSON data mismatch: %v", reason)
+			}
+		})
+	}
+}
+
 func TestBinarySerialization(t *testing.T) {
 	type args struct {
 		data    main.BoardshapesData
@@ -123,3 +229,139 @@ func TestJsonSerialization(t *testing.T) {
 		})
 	}
 }
+
+func TestMetadataSerialization(t *testing.T) {
+	withMetadata := testData(testShape(0, main.Black, "Black"))
+	withMetadata.Metadata = &main.Metadata{
+		ImageWidth:   1920,
+		ImageHeight:  1080,
+		SourceWidth:  3840,
+		SourceHeight: 2160,
+		Scale:        0.5,
+		Options:      main.ShapeCreationOptions{CurveTolerance: 1.5}.Summary(),
+		CreatedAt:    time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Tags:         map[string]string{"board": "lub", "room": ""},
+	}
+	withMetadata.Transform = &main.Homography{2, 0, 1, 0, 2, 1, 0, 0, 1}
+
+	withoutMetadata := testData(testShape(0, main.Black, "Black"))
+
+	tests := []struct {
+		name string
+		data *main.BoardshapesData
+	}{
+		{name: "with metadata", data: withMetadata},
+		{name: "without metadata", data: withoutMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if err := BinarySerialize(w, tt.data, nil); err != nil {
+				t.Fatalf("BinarySerialize() error = %v", err)
+			}
+			result, err := BinaryDeserialize(w, nil)
+			if err != nil {
+				t.Fatalf("BinaryDeserialize() error = %v", err)
+			}
+			if equal, reason := tt.data.Equal(*result); !equal {
+				t.Errorf("Binary data mismatch: %v", reason)
+			}
+
+			w.Reset()
+			if err := JsonSerialize(w, tt.data); err != nil {
+				t.Fatalf("JsonSerialize() error = %v", err)
+			}
+			result, err = JsonDeserialize(w, nil)
+			if err != nil {
+				t.Fatalf("JsonDeserialize() error = %v", err)
+			}
+			if equal, reason := tt.data.Equal(*result); !equal {
+				t.Errorf("JSON data mismatch: %v", reason)
+			}
+		})
+	}
+}
+
+func TestBinaryCompression(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{})
+	options := SerializationOptions{UseMasks: false, Checksum: true}
+
+	uncompressed := &bytes.Buffer{}
+	if err := BinarySerialize(uncompressed, data, &options); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	options.Compression = COMPRESSION_FLATE
+	compressed := &bytes.Buffer{}
+	if err := BinarySerialize(compressed, data, &options); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	if compressed.Len() >= uncompressed.Len() {
+		t.Errorf("compressed data is %d bytes, not smaller than the uncompressed %d bytes", compressed.Len(), uncompressed.Len())
+	}
+
+	// cutting compressed data short should never go unnoticed
+	valid := compressed.Bytes()
+	for _, n := range []int{len(valid) / 4, len(valid) / 2, len(valid) - 1} {
+		if _, err := BinaryDeserialize(bytes.NewReader(valid[:n]), nil); err == nil {
+			t.Errorf("BinaryDeserialize() with the data cut to %d of %d bytes didn't fail", n, len(valid))
+		}
+	}
+
+	// the compressed part is a little smaller than the uncompressed data, which has no compression chunk
+	limit := map[string]any{"maxDecompressedSize": int64(uncompressed.Len())}
+	if _, err := BinaryDeserialize(bytes.NewReader(valid), limit); err != nil {
+		t.Errorf("BinaryDeserialize() with a large enough decompressed size limit error = %v", err)
+	}
+	limit["maxDecompressedSize"] = int64(uncompressed.Len() / 2)
+	if _, err := BinaryDeserialize(bytes.NewReader(valid), limit); !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Errorf("BinaryDeserialize() with too small a decompressed size limit error = %v, want %v", err, ErrDecompressedTooLarge)
+	}
+
+	options.Compression = 99
+	if err := BinarySerialize(&bytes.Buffer{}, data, &options); err != ErrUnknownCompression {
+		t.Errorf("BinarySerialize() with an unknown compression error = %v, want %v", err, ErrUnknownCompression)
+	}
+}
+
+func TestBinaryDeltaGeometry(t *testing.T) {
+	// like an unoptimized path, with a vertex for every pixel along the outline
+	shape := testShape(0, main.Black, "Black")
+	shape.Path = make(main.Path, 0, 32)
+	for i := range 8 {
+		shape.Path = append(shape.Path, main.Vertex{X: uint16(i), Y: 0})
+	}
+	for i := range 8 {
+		shape.Path = append(shape.Path, main.Vertex{X: 8, Y: uint16(i)})
+	}
+	for i := range 8 {
+		shape.Path = append(shape.Path, main.Vertex{X: uint16(8 - i), Y: 8})
+	}
+	for i := range 8 {
+		shape.Path = append(shape.Path, main.Vertex{X: 0, Y: uint16(8 - i)})
+	}
+	shape.Holes = []main.Path{{{X: 2, Y: 2}, {X: 2, Y: 3}, {X: 2, Y: 4}, {X: 3, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 3}, {X: 4, Y: 2}, {X: 3, Y: 2}}}
+	data := testData(shape)
+	options := SerializationOptions{UseMasks: true}
+
+	absolute := &bytes.Buffer{}
+	if err := BinarySerialize(absolute, data, &options); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	options.DeltaGeometry = true
+	delta := &bytes.Buffer{}
+	if err := BinarySerialize(delta, data, &options); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	t.Logf("absolute: %d bytes, delta: %d bytes", absolute.Len(), delta.Len())
+	if delta.Len() >= absolute.Len() {
+		t.Errorf("delta geometry is %d bytes, not smaller than absolute geometry's %d bytes", delta.Len(), absolute.Len())
+	}
+
+	result, err := BinaryDeserialize(delta, nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+	if equal, reason := data.Equal(*result); !equal {
+		t.Errorf("Data mismatch: %v", reason)
+	}
+}
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"image/color"
+	"time"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+type JSONData struct {
+	Version          string             `json:"version"`
+	Palette          []JSONPaletteEntry `json:"palette,omitempty"`
+	PaletteTolerance float64            `json:"paletteTolerance,omitempty"`
+	Metadata         *JSONMetadata      `json:"metadata,omitempty"`
+	Transform        *main.Homography   `json:"transform,omitempty"`
+	Shapes           []JSONShapeData    `json:"shapes"`
+}
+
+type JSONMetadata struct {
+	ImageWidth   int               `json:"imageWidth"`
+	ImageHeight  int               `json:"imageHeight"`
+	SourceWidth  int               `json:"sourceWidth,omitempty"`
+	SourceHeight int               `json:"sourceHeight,omitempty"`
+	Scale        float64           `json:"scale"`
+	Options      map[string]string `json:"options,omitempty"`
+	CreatedAt    time.Time         `json:"createdAt,omitzero"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+type JSONPaletteEntry struct {
+	Name  string      `json:"name"`
+	Color color.NRGBA `json:"color"`
+}
+
+type JSONShapeData struct {
+	Number      int            `json:"number"`
+	CornerX     int            `json:"cornerX"`
+	CornerY     int            `json:"cornerY"`
+	Shape       []uint16       `json:"path"`
+	Color       color.NRGBA    `json:"color"`
+	ColorString string         `json:"colorString"`
+	Image       string         `json:"image"`
+	Holes       [][]uint16     `json:"holes,omitempty"`
+	Open        bool           `json:"open,omitempty"`
+	Primitive   *JSONPrimitive `json:"primitive,omitempty"`
+	Curves      []float32      `json:"curves,omitempty"`
+	HoleCurves  [][]float32    `json:"holeCurves,omitempty"`
+}
+
+type JSONPrimitive struct {
+	Kind       string   `json:"kind"`
+	Confidence float64  `json:"confidence"`
+	CenterX    float64  `json:"centerX"`
+	CenterY    float64  `json:"centerY"`
+	RadiusX    float64  `json:"radiusX"`
+	RadiusY    float64  `json:"radiusY"`
+	Angle      float64  `json:"angle"`
+	Corners    []uint16 `json:"corners,omitempty"`
+}
@@ -1,9 +1,20 @@
 package shared
 
 import (
+	"encoding/binary"
+	"errors"
 	"strings"
 )
 
+var ErrChecksumMismatch = errors.New("deserialization: checksum does not match the data, it may be corrupt")
+var ErrChecksumMissing = errors.New("deserialization: data has no checksum, it may have been cut short")
+var ErrUnknownCompression = errors.New("serialization: unknown compression method")
+var ErrDecompressedTooLarge = errors.New("deserialization: data is larger than the limit once decompressed")
+var ErrImageTooLarge = errors.New("deserialization: shape image has more pixels than the limit")
+
+// The most pixels a shape's image can have when deserializing, unless the options give another limit.
+const DEFAULT_MAX_IMAGE_PIXELS = 1 << 25
+
 // the byte is the chunk ID
 type ErrUnknownChunkType byte
 
@@ -14,3 +25,18 @@ func (e ErrUnknownChunkType) Error() string {
 func TrimNullByte(s string) string {
 	return strings.TrimRight(s, "\x00")
 }
+
+// A chunk of Boardshapes binary data. Data is everything in the chunk after its ID (and its length, in versions that have it).
+type Chunk struct {
+	ID   byte
+	Data []byte
+}
+
+// Returns the number of the shape the chunk belongs to, if it's a shape chunk.
+// Shape chunks have IDs from 8 up, and always start with the shape's number.
+func (c Chunk) ShapeNumber() (int, bool) {
+	if c.ID < 8 || len(c.Data) < 4 {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(c.Data)), true
+}
@@ -0,0 +1,261 @@
+package serialization
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+
+	main "github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization/shared"
+	v0_1 "github.com/boardshapes/boardshapes/serialization/v0.1"
+	v0_2 "github.com/boardshapes/boardshapes/serialization/v0.2"
+)
+
+// A chunk of binary data. Data is everything in the chunk after its ID and length.
+type Chunk = shared.Chunk
+
+var ErrHeaderWritten = errors.New("serialization: header has already been written")
+var ErrUnknownCompression = shared.ErrUnknownCompression
+
+// Writes binary data chunk by chunk, or shape by shape, without building all of it in memory first.
+type Encoder struct {
+	w *bufio.Writer
+	// where the data ends up, under any compression
+	out         io.Writer
+	compressor  *flate.Writer
+	hash        hash.Hash32
+	options     *SerializationOptions
+	wroteHeader bool
+	// colors that have been written in color table chunks
+	colors map[color.NRGBA]bool
+}
+
+func NewEncoder(w io.Writer, options *SerializationOptions) *Encoder {
+	if options == nil {
+		options = &DefaultOptions
+	}
+	hash := crc32.NewIEEE()
+	return &Encoder{
+		w:       bufio.NewWriter(io.MultiWriter(w, hash)),
+		out:     w,
+		hash:    hash,
+		options: options,
+		colors:  make(map[color.NRGBA]bool),
+	}
+}
+
+// Writes the version chunk, then chunks for the data's color table and metadata, but not its shapes.
+// It can only be written once, and before any shapes.
+func (e *Encoder) WriteHeader(data *main.BoardshapesData) error {
+	if e.wroteHeader {
+		return ErrHeaderWritten
+	}
+	if err := e.writeVersion(); err != nil {
+		return err
+	}
+	if err := e.writeColorTable(colorTable(data), len(data.Palette.Entries), data.Palette.Tolerance); err != nil {
+		return err
+	}
+	_, err := e.w.Write(appendMetadataChunk(nil, data))
+	return err
+}
+
+// Writes the chunks of a shape. If the header hasn't been written, only the version chunk is written first.
+// If the shape's color has a name that isn't in a color table yet, a color table chunk with just it is written too.
+func (e *Encoder) WriteShape(shape main.ShapeData) error {
+	if !e.wroteHeader {
+		if err := e.writeVersion(); err != nil {
+			return err
+		}
+	}
+	if shape.ColorName != "" && shape.Color != nil {
+		nrgba := main.GetNRGBA(shape.Color)
+		if !e.colors[nrgba] {
+			if err := e.writeColorTable([]main.PaletteEntry{{Name: shape.ColorName, Color: nrgba}}, 0, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	chunk, err := appendShapeChunks(nil, shape, e.options)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(chunk)
+	return err
+}
+
+// Writes a chunk as it is, such as one read by a [Decoder]. Chunks of unknown types can be written too,
+// since readers use their lengths to skip them. Compression chunks are left out, since the encoder's options
+// decide whether what it writes is compressed, and version chunks are written with the encoder's own version,
+// since everything it writes is in that version's format.
+func (e *Encoder) WriteChunk(chunk Chunk) error {
+	switch chunk.ID {
+	case CHUNK_COMPRESSION:
+		return nil
+	case CHUNK_VERSION:
+		return e.writeVersion()
+	}
+
+	header := binary.BigEndian.AppendUint32([]byte{chunk.ID}, uint32(len(chunk.Data)))
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	_, err := e.w.Write(chunk.Data)
+	return err
+}
+
+// Writes any buffered data to the underlying writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Writes the checksum chunk if the options ask for it, then flushes and ends any compression.
+// Nothing should be written after it.
+func (e *Encoder) Close() error {
+	if e.options.Checksum {
+		// the checksum covers everything written before it
+		if err := e.w.Flush(); err != nil {
+			return err
+		}
+		chunk := []byte{CHUNK_CHECKSUM, 0, 0, 0, 0}
+		chunk = binary.BigEndian.AppendUint32(chunk, e.hash.Sum32())
+		setChunkLengths(chunk, []int{0})
+		if _, err := e.w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	if e.compressor != nil {
+		return e.compressor.Close()
+	}
+	return nil
+}
+
+func (e *Encoder) writeVersion() error {
+	return e.writeVersionChunk(append([]byte(main.VERSION), 0))
+}
+
+// Writes the version chunk, then the compression chunk if the options ask for compression,
+// after which everything is written through the compressor.
+func (e *Encoder) writeVersionChunk(data []byte) error {
+	e.wroteHeader = true
+	if _, err := e.w.Write(append([]byte{CHUNK_VERSION}, data...)); err != nil {
+		return err
+	}
+
+	switch e.options.Compression {
+	case COMPRESSION_NONE:
+		return nil
+	case COMPRESSION_FLATE:
+	default:
+		return ErrUnknownCompression
+	}
+
+	chunk := []byte{CHUNK_COMPRESSION, 0, 0, 0, 0, byte(e.options.Compression)}
+	setChunkLengths(chunk, []int{0})
+	if _, err := e.w.Write(chunk); err != nil {
+		return err
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+
+	// the checksum is of the data before it was compressed
+	compressor, err := flate.NewWriter(e.out, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	e.compressor = compressor
+	e.w = bufio.NewWriter(io.MultiWriter(compressor, e.hash))
+	return nil
+}
+
+// Writes a color table chunk whose first paletteSize entries are palette entries, and the rest other shape colors.
+func (e *Encoder) writeColorTable(entries []main.PaletteEntry, paletteSize int, tolerance float64) error {
+	chunk := []byte{CHUNK_COLOR_TABLE, 0, 0, 0, 0}
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(entries)))
+
+	for _, entry := range entries {
+		nrgba := entry.Color
+		chunk = append(chunk, nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+		chunk = append(chunk, entry.Name...)
+		chunk = append(chunk, 0)
+		e.colors[nrgba] = true
+	}
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(paletteSize))
+	chunk = binary.BigEndian.AppendUint64(chunk, math.Float64bits(tolerance))
+	setChunkLengths(chunk, []int{0})
+	_, err := e.w.Write(chunk)
+	return err
+}
+
+// Reads binary data chunk by chunk, or shape by shape, without reading all of it into memory first.
+// Each version of the binary format has its own decoder.
+type Decoder interface {
+	// Reads the next chunk, returning io.EOF once there are none left.
+	NextChunk() (Chunk, error)
+	// Reads the chunks of the next shape, returning io.EOF once there are none left.
+	// A shape's chunks must all come before the next shape's.
+	NextShape() (main.ShapeData, error)
+	// The version of the data, once its version chunk has been read.
+	Version() string
+	// The data's palette, from the color table chunks that have been read so far.
+	// Shape colors that color tables list outside of the palette aren't included.
+	Palette() main.Palette
+	// The data's metadata and transform, once its metadata chunk has been read.
+	Metadata() (*main.Metadata, *main.Homography)
+}
+
+type NewDecoderFunc func(r io.Reader, options map[string]any) Decoder
+
+var binaryDecoders = map[string]NewDecoderFunc{
+	"0.1": func(r io.Reader, options map[string]any) Decoder { return v0_1.NewDecoder(r, options) },
+	"0.2": func(r io.Reader, options map[string]any) Decoder { return v0_2.NewDecoder(r, options) },
+}
+
+// Returns a decoder for the version of the data, which is read from the version chunk at its start.
+// Options are the same as for [BinaryDeserialize].
+func NewDecoder(r io.Reader, options map[string]any) (Decoder, error) {
+	br := bufio.NewReader(r)
+	majorMinor, err := peekBinaryVersion(br)
+	if err != nil {
+		return nil, err
+	}
+
+	newDecoderFunc, ok := binaryDecoders[majorMinor]
+	if !ok {
+		return nil, ErrIncompatibleVersion
+	}
+	return newDecoderFunc(br, options), nil
+}
+
+// Returns the major and minor version from the version chunk at the start of the data, without consuming it.
+func peekBinaryVersion(br *bufio.Reader) (string, error) {
+	b, _ := br.Peek(br.Size())
+	if len(b) == 0 || b[0] != CHUNK_VERSION {
+		return "", ErrVersionNotFound
+	}
+
+	nullIndex := bytes.IndexByte(b[1:], 0)
+	if nullIndex == -1 {
+		return "", ErrVersionNotFound
+	}
+	version := string(b[1 : nullIndex+1])
+
+	vnums := strings.Split(version, ".")
+	if len(vnums) < 2 {
+		return "", ErrVersionNotFound
+	}
+	return vnums[0] + "." + vnums[1], nil
+}
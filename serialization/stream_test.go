@@ -0,0 +1,392 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	main "github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization/shared"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"),
+		main.ShapeCreationOptions{ExtractCenterlines: true, RecognizePrimitives: true, CurveTolerance: 1.5})
+
+	// shapes are written one at a time, without a header
+	w := &bytes.Buffer{}
+	encoder := NewEncoder(w, nil)
+	for _, shape := range data.Shapes {
+		if err := encoder.WriteShape(shape); err != nil {
+			t.Fatalf("WriteShape() error = %v", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := encoder.WriteHeader(data); err != ErrHeaderWritten {
+		t.Errorf("WriteHeader() after shapes error = %v, want %v", err, ErrHeaderWritten)
+	}
+
+	decoder, err := NewDecoder(bytes.NewReader(w.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	for i, want := range data.Shapes {
+		shape, err := decoder.NextShape()
+		if err != nil {
+			t.Fatalf("NextShape() error = %v", err)
+		}
+		if !want.Equal(shape) {
+			t.Errorf("NextShape() shape %d mismatch", i)
+		}
+	}
+	if _, err := decoder.NextShape(); err != io.EOF {
+		t.Errorf("NextShape() after the last shape error = %v, want io.EOF", err)
+	}
+	if decoder.Version() != main.VERSION {
+		t.Errorf("Version() = %q, want %q", decoder.Version(), main.VERSION)
+	}
+}
+
+func TestDecoderFilterChunks(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{})
+	w := &bytes.Buffer{}
+	if err := BinarySerialize(w, data, nil); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+
+	// copy everything but the masks
+	decoder, err := NewDecoder(w, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	filtered := &bytes.Buffer{}
+	encoder := NewEncoder(filtered, nil)
+	for {
+		chunk, err := decoder.NextChunk()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("NextChunk() error = %v", err)
+		}
+		// the checksum is written again for the new data
+		if chunk.ID == CHUNK_SHAPE_MASK || chunk.ID == CHUNK_CHECKSUM {
+			continue
+		}
+		if err := encoder.WriteChunk(chunk); err != nil {
+			t.Fatalf("WriteChunk() error = %v", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	result, err := BinaryDeserialize(filtered, nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+	if len(result.Shapes) != len(data.Shapes) {
+		t.Fatalf("BinaryDeserialize() read %d shapes, want %d", len(result.Shapes), len(data.Shapes))
+	}
+	for _, shape := range result.Shapes {
+		if shape.Image != nil {
+			t.Errorf("shape %d still has an image", shape.Number)
+		}
+		if shape.ColorName == "" {
+			t.Errorf("shape %d lost its color name", shape.Number)
+		}
+	}
+}
+
+func TestDecoderSkipsUnknownChunks(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{CurveTolerance: 1.5})
+	w := &bytes.Buffer{}
+	if err := BinarySerialize(w, data, nil); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+
+	// add chunks of types that don't exist (yet) after every chunk
+	decoder, err := NewDecoder(w, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	withUnknown := &bytes.Buffer{}
+	encoder := NewEncoder(withUnknown, nil)
+	for {
+		chunk, err := decoder.NextChunk()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("NextChunk() error = %v", err)
+		}
+		if chunk.ID == CHUNK_CHECKSUM {
+			continue
+		}
+		encoder.WriteChunk(chunk)
+		encoder.WriteChunk(Chunk{ID: 7, Data: []byte("not a shape chunk\x00")})
+		if number, ok := chunk.ShapeNumber(); ok {
+			encoder.WriteChunk(Chunk{ID: 255, Data: []byte{0, 0, 0, byte(number), 1, 2, 3}})
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	result, err := BinaryDeserialize(withUnknown, nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+	if equal, reason := data.Equal(*result); !equal {
+		t.Errorf("Data mismatch: %v", reason)
+	}
+}
+
+func TestBinaryDeserializeV0_1(t *testing.T) {
+	// version 0.1 chunks don't have lengths
+	d := append([]byte{CHUNK_VERSION}, "0.1.1\x00"...)
+	d = append(d, CHUNK_COLOR_TABLE, 0, 0, 0, 1, 255, 0, 0, 255)
+	d = append(d, "Red\x00"...)
+	d = append(d, CHUNK_SHAPE_GEOMETRY, 0, 0, 0, 4, 0, 10, 0, 20, 0, 0, 0, 3, 0, 0, 0, 0, 0, 5, 0, 0, 0, 5, 0, 5)
+	d = append(d, CHUNK_SHAPE_COLOR, 0, 0, 0, 4, 255, 0, 0, 255)
+
+	result, err := BinaryDeserialize(bytes.NewReader(d), nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+	if result.Version != "0.1.1" || len(result.Shapes) != 1 {
+		t.Fatalf("BinaryDeserialize() = version %q with %d shapes, want version 0.1.1 with 1 shape", result.Version, len(result.Shapes))
+	}
+	shape := result.Shapes[0]
+	if shape.Number != 4 || shape.CornerX != 10 || shape.CornerY != 20 || len(shape.Path) != 3 || shape.ColorName != "Red" {
+		t.Errorf("BinaryDeserialize() shape = %+v", shape)
+	}
+}
+
+func TestBinaryDeserializeV0_1RejectsNewChunks(t *testing.T) {
+	// holes only exist since version 0.2, so a version 0.1 reader can't find where their chunk ends
+	d := append([]byte{CHUNK_VERSION}, "0.1.1\x00"...)
+	d = append(d, CHUNK_SHAPE_GEOMETRY, 0, 0, 0, 4, 0, 10, 0, 20, 0, 0, 0, 3, 0, 0, 0, 0, 0, 5, 0, 0, 0, 5, 0, 5)
+	d = append(d, CHUNK_SHAPE_HOLES, 0, 0, 0, 4, 0, 0, 0, 0)
+
+	_, err := BinaryDeserialize(bytes.NewReader(d), nil)
+	var unknown shared.ErrUnknownChunkType
+	if !errors.As(err, &unknown) || byte(unknown) != CHUNK_SHAPE_HOLES {
+		t.Errorf("BinaryDeserialize() error = %v, want unknown chunk type %d", err, CHUNK_SHAPE_HOLES)
+	}
+}
+
+func TestEncoderCopiesV0_1(t *testing.T) {
+	d := append([]byte{CHUNK_VERSION}, "0.1.1\x00"...)
+	d = append(d, CHUNK_COLOR_TABLE, 0, 0, 0, 1, 255, 0, 0, 255)
+	d = append(d, "Red\x00"...)
+	d = append(d, CHUNK_SHAPE_GEOMETRY, 0, 0, 0, 4, 0, 10, 0, 20, 0, 0, 0, 3, 0, 0, 0, 0, 0, 5, 0, 0, 0, 5, 0, 5)
+	d = append(d, CHUNK_SHAPE_COLOR, 0, 0, 0, 4, 255, 0, 0, 255)
+	original, err := BinaryDeserialize(bytes.NewReader(d), nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+
+	// the chunks are written with lengths, so they have to be marked as the encoder's version
+	decoder, err := NewDecoder(bytes.NewReader(d), nil)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	w := &bytes.Buffer{}
+	encoder := NewEncoder(w, nil)
+	for {
+		chunk, err := decoder.NextChunk()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("NextChunk() error = %v", err)
+		}
+		if err := encoder.WriteChunk(chunk); err != nil {
+			t.Fatalf("WriteChunk() error = %v", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	result, err := BinaryDeserialize(w, nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() of the copy error = %v", err)
+	}
+	if result.Version != main.VERSION {
+		t.Errorf("BinaryDeserialize() of the copy has version %q, want %q", result.Version, main.VERSION)
+	}
+	if len(result.Shapes) != 1 {
+		t.Fatalf("BinaryDeserialize() of the copy returned %d shapes, want 1", len(result.Shapes))
+	}
+	want, got := original.Shapes[0], result.Shapes[0]
+	if got.Number != want.Number || got.CornerX != want.CornerX || got.CornerY != want.CornerY ||
+		!slices.Equal(got.Path, want.Path) || got.ColorName != want.ColorName {
+		t.Errorf("BinaryDeserialize() of the copy shape = %+v, want %+v", got, want)
+	}
+}
+
+func TestBinaryDeserializeCorruptData(t *testing.T) {
+	data := &main.BoardshapesData{
+		Version: main.VERSION,
+		Shapes: []main.ShapeData{{
+			Number: 0,
+			Path:   main.Path{{X: 0, Y: 0}, {X: 5, Y: 0}, {X: 5, Y: 5}},
+			Color:  main.Black,
+		}},
+	}
+	w := &bytes.Buffer{}
+	if err := BinarySerialize(w, data, &SerializationOptions{Checksum: true, DeltaGeometry: true}); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	valid := w.Bytes()
+
+	if _, err := BinaryDeserialize(bytes.NewReader(valid), map[string]any{"requireChecksum": true}); err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+
+	// the last vertex's Y, after the shape number, corner, number of vertices and the other deltas,
+	// changed to a vertex that's still valid so only the checksum can catch it
+	corrupt := bytes.Clone(valid)
+	corrupt[findChunk(t, valid, CHUNK_SHAPE_DELTA_GEOMETRY)+CHUNK_HEADER_SIZE+14] ^= 0x02
+	if _, err := BinaryDeserialize(bytes.NewReader(corrupt), nil); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("BinaryDeserialize() with a corrupt vertex error = %v, want %v", err, ErrChecksumMismatch)
+	}
+
+	if _, err := BinaryDeserialize(bytes.NewReader(nil), nil); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("BinaryDeserialize() with no data error = %v, want %v", err, ErrVersionNotFound)
+	}
+
+	for n := 1; n < len(valid); n++ {
+		if _, err := BinaryDeserialize(bytes.NewReader(valid[:n]), map[string]any{"requireChecksum": true}); err == nil {
+			t.Errorf("BinaryDeserialize() with the data cut to %d of %d bytes didn't fail", n, len(valid))
+		}
+	}
+
+	// counts that claim far more than the chunk holds, which mustn't be allocated before finding that out
+	const huge = "\xff\xff\xff\xff"
+	shape := "\x00\x00\x00\x00"
+	tests := []struct {
+		name  string
+		id    byte
+		chunk string
+	}{
+		{"too many colors", CHUNK_COLOR_TABLE, huge},
+		{"too many vertices", CHUNK_SHAPE_GEOMETRY, shape + "\x00\x00\x00\x00" + huge},
+		{"too many holes", CHUNK_SHAPE_HOLES, shape + huge},
+		{"too many hole vertices", CHUNK_SHAPE_HOLES, shape + "\x00\x00\x00\x01" + huge},
+		{"too many corners", CHUNK_SHAPE_PRIMITIVE, shape + string(make([]byte, 1+6*8)) + huge},
+		{"too many curves", CHUNK_SHAPE_CURVES, shape + "\x7f\xff\xff\xff"},
+		{"too many hole curves", CHUNK_SHAPE_CURVES, shape + "\x00\x00\x00\x00" + huge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupt := binaryWithChunk(tt.id, []byte(tt.chunk))
+			if _, err := BinaryDeserialize(bytes.NewReader(corrupt), nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("BinaryDeserialize() error = %v, want %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+func TestBinaryDeserializeLargeMask(t *testing.T) {
+	// a 2-pixel wide mask of 2^30 pixels, from just a few bytes of run-lengths
+	mask := binary.AppendUvarint([]byte{0, 0, 0, 0, 0, 2, 1}, 1<<30)
+	mask = append(mask, 0)
+	huge := binaryWithChunk(CHUNK_SHAPE_MASK, mask)
+	if _, err := BinaryDeserialize(bytes.NewReader(huge), nil); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("BinaryDeserialize() of a huge mask error = %v, want %v", err, ErrImageTooLarge)
+	}
+
+	data := testData(testShape(0, main.Black, "Black"))
+	w := &bytes.Buffer{}
+	if err := BinarySerialize(w, data, &SerializationOptions{UseMasks: true}); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	// the test shape's mask is 8x8
+	for _, tt := range []struct {
+		maxImagePixels int
+		wantErr        error
+	}{{64, nil}, {63, ErrImageTooLarge}, {0, nil}} {
+		_, err := BinaryDeserialize(bytes.NewReader(w.Bytes()), map[string]any{"maxImagePixels": tt.maxImagePixels})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("BinaryDeserialize() with at most %d pixels error = %v, want %v", tt.maxImagePixels, err, tt.wantErr)
+		}
+	}
+}
+
+// Returns binary data with just the version chunk and the chunk.
+func binaryWithChunk(id byte, data []byte) []byte {
+	b := append([]byte{CHUNK_VERSION}, main.VERSION+"\x00"...)
+	b = binary.BigEndian.AppendUint32(append(b, id), uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestBinaryDeserializeCorruptDeltaGeometry(t *testing.T) {
+	data := &main.BoardshapesData{
+		Version: main.VERSION,
+		Shapes: []main.ShapeData{{
+			Number: 0,
+			Path:   main.Path{{X: 0, Y: 0}, {X: 5, Y: 0}, {X: 5, Y: 5}},
+			Holes:  []main.Path{{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 2, Y: 2}}},
+			Color:  main.Black,
+		}},
+	}
+	w := &bytes.Buffer{}
+	// without a checksum, so the chunks themselves have to catch the corruption
+	if err := BinarySerialize(w, data, &SerializationOptions{DeltaGeometry: true}); err != nil {
+		t.Fatalf("BinarySerialize() error = %v", err)
+	}
+	valid := w.Bytes()
+	// the shape number and corner come before the vertices
+	geometry := findChunk(t, valid, CHUNK_SHAPE_DELTA_GEOMETRY) + CHUNK_HEADER_SIZE + 8
+	holes := findChunk(t, valid, CHUNK_SHAPE_DELTA_HOLES) + CHUNK_HEADER_SIZE + 4
+
+	tests := []struct {
+		name    string
+		offset  int
+		value   byte
+		wantErr error
+	}{
+		// the vertices would need more data than the chunk has
+		{"too many vertices", geometry, 100, io.ErrUnexpectedEOF},
+		{"too many holes", holes, 100, io.ErrUnexpectedEOF},
+		{"too many hole vertices", holes + 1, 100, io.ErrUnexpectedEOF},
+		// zigzag encoded -1, which moves the first vertex left of the corner
+		{"vertex out of range", geometry + 1, 1, nil},
+		{"hole vertex out of range", holes + 2, 1, nil},
+		// a varint that doesn't end within the chunk
+		{"unfinished vertex", geometry + 6, 0x80, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupt := bytes.Clone(valid)
+			corrupt[tt.offset] = tt.value
+			_, err := BinaryDeserialize(bytes.NewReader(corrupt), nil)
+			if err == nil {
+				t.Fatalf("BinaryDeserialize() didn't fail")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("BinaryDeserialize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Returns where the first chunk of the type starts in the binary data, which mustn't be compressed.
+func findChunk(t *testing.T, data []byte, id byte) int {
+	t.Helper()
+	// skip the version chunk, whose ID is also 0
+	i := 1 + bytes.IndexByte(data[1:], 0) + 1
+	for i+CHUNK_HEADER_SIZE <= len(data) {
+		if data[i] == id {
+			return i
+		}
+		i += CHUNK_HEADER_SIZE + int(binary.BigEndian.Uint32(data[i+1:]))
+	}
+	t.Fatalf("no chunk of type %d in the data", id)
+	return -1
+}
@@ -0,0 +1,180 @@
+package serialization
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+type SvgOptions struct {
+	// If true, each shape's image is embedded as a PNG, in its own layer below the shapes.
+	EmbedImages bool
+}
+
+var DefaultSvgOptions = SvgOptions{}
+
+// Writes the shapes as an SVG image, with one layer (a group that Inkscape also treats as a layer) for each color.
+// Shapes with curves are drawn with their curves instead of their paths.
+func SvgSerialize(w io.Writer, data *main.BoardshapesData, options *SvgOptions) error {
+	if options == nil {
+		options = &DefaultSvgOptions
+	}
+
+	// the image the shapes came from, if known, or else just big enough for all of them
+	width, height := 0, 0
+	if data.Metadata != nil {
+		width, height = data.Metadata.ImageWidth, data.Metadata.ImageHeight
+	}
+	for _, shape := range data.Shapes {
+		if shape.Image != nil {
+			width = max(width, shape.CornerX+shape.Image.Bounds().Dx())
+			height = max(height, shape.CornerY+shape.Image.Bounds().Dy())
+		}
+		for _, v := range shape.Path {
+			width = max(width, shape.CornerX+int(v.X)+1)
+			height = max(height, shape.CornerY+int(v.Y)+1)
+		}
+	}
+
+	// group the shapes by color, in the order the colors first appear
+	layers := make([]string, 0)
+	layerShapes := make(map[string][]main.ShapeData)
+	for _, shape := range data.Shapes {
+		name := shape.ColorName
+		if name == "" {
+			name = svgColor(main.GetNRGBA(shape.Color))
+		}
+		if _, ok := layerShapes[name]; !ok {
+			layers = append(layers, name)
+		}
+		layerShapes[name] = append(layerShapes[name], shape)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" `+
+		`width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+
+	if options.EmbedImages {
+		fmt.Fprintf(bw, `  <g id="images" inkscape:groupmode="layer" inkscape:label="Images">`+"\n")
+		for _, shape := range data.Shapes {
+			if shape.Image == nil || shape.Image.Bounds().Empty() {
+				continue
+			}
+			var pngBuf bytes.Buffer
+			if err := png.Encode(&pngBuf, shape.Image); err != nil {
+				return err
+			}
+			bds := shape.Image.Bounds()
+			fmt.Fprintf(bw, `    <image id="image-%d" x="%d" y="%d" width="%d" height="%d" href="data:image/png;base64,%s"/>`+"\n",
+				shape.Number, shape.CornerX, shape.CornerY, bds.Dx(), bds.Dy(), base64.StdEncoding.EncodeToString(pngBuf.Bytes()))
+		}
+		fmt.Fprintf(bw, "  </g>\n")
+	}
+
+	for i, name := range layers {
+		fmt.Fprintf(bw, `  <g id="layer-%d" inkscape:groupmode="layer" inkscape:label="%s">`+"\n", i, svgEscape(name))
+		for _, shape := range layerShapes[name] {
+			writeSvgShape(bw, shape)
+		}
+		fmt.Fprintf(bw, "  </g>\n")
+	}
+
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
+
+func writeSvgShape(w io.Writer, shape main.ShapeData) {
+	nrgba := main.GetNRGBA(shape.Color)
+	paint := fmt.Sprintf(`fill="%s"`, svgColor(nrgba))
+	if shape.Open {
+		paint = fmt.Sprintf(`fill="none" stroke="%s" stroke-linecap="round" stroke-linejoin="round"`, svgColor(nrgba))
+	}
+	if nrgba.A < 255 {
+		paint += fmt.Sprintf(` opacity="%s"`, strconv.FormatFloat(float64(nrgba.A)/255, 'f', 3, 64))
+	}
+	transform := fmt.Sprintf(`transform="translate(%d %d)"`, shape.CornerX, shape.CornerY)
+
+	if len(shape.Curves) == 0 && len(shape.Holes) == 0 {
+		element := "polygon"
+		if shape.Open {
+			element = "polyline"
+		}
+		points := make([]string, len(shape.Path))
+		for i, v := range shape.Path {
+			points[i] = fmt.Sprintf("%d,%d", v.X, v.Y)
+		}
+		fmt.Fprintf(w, `    <%s id="shape-%d" %s %s points="%s"/>`+"\n",
+			element, shape.Number, transform, paint, strings.Join(points, " "))
+		return
+	}
+
+	var d strings.Builder
+	if len(shape.Curves) > 0 {
+		writeSvgCurves(&d, shape.Curves, !shape.Open)
+		for _, curves := range shape.HoleCurves {
+			writeSvgCurves(&d, curves, true)
+		}
+	} else {
+		writeSvgPolygon(&d, shape.Path, !shape.Open)
+		for _, hole := range shape.Holes {
+			writeSvgPolygon(&d, hole, true)
+		}
+	}
+	fmt.Fprintf(w, `    <path id="shape-%d" %s %s fill-rule="evenodd" d="%s"/>`+"\n",
+		shape.Number, transform, paint, strings.TrimSpace(d.String()))
+}
+
+func writeSvgPolygon(d *strings.Builder, path []main.Vertex, closed bool) {
+	for i, v := range path {
+		if i == 0 {
+			fmt.Fprintf(d, "M%d,%d", v.X, v.Y)
+		} else {
+			fmt.Fprintf(d, " L%d,%d", v.X, v.Y)
+		}
+	}
+	if closed && len(path) > 0 {
+		d.WriteString(" Z")
+	}
+	d.WriteString(" ")
+}
+
+func writeSvgCurves(d *strings.Builder, curves []main.CubicBezier, closed bool) {
+	if len(curves) == 0 {
+		return
+	}
+	fmt.Fprintf(d, "M%s,%s", svgNumber(curves[0].Start.X), svgNumber(curves[0].Start.Y))
+	for _, c := range curves {
+		fmt.Fprintf(d, " C%s,%s %s,%s %s,%s",
+			svgNumber(c.Control1.X), svgNumber(c.Control1.Y),
+			svgNumber(c.Control2.X), svgNumber(c.Control2.Y),
+			svgNumber(c.End.X), svgNumber(c.End.Y))
+	}
+	if closed {
+		d.WriteString(" Z")
+	}
+	d.WriteString(" ")
+}
+
+func svgNumber(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,1082 @@
+package serialization
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	main "github.com/boardshapes/boardshapes"
+	"golang.org/x/image/colornames"
+)
+
+var ErrInvalidSvg = errors.New("svg: invalid or unsupported svg")
+var ErrSvgTooLarge = errors.New("svg: drawing is too large to import")
+
+// The length (in pixels) of the straight lines that curves are broken up into for shape paths.
+const SVG_FLATTENING_STEP = 2.0
+
+// The most points the paths of an SVG image can have, once its curves are broken up.
+const SVG_MAX_POINTS = 1 << 22
+
+// The most pixels the images of an SVG image's shapes can have altogether.
+const SVG_MAX_PIXELS = 1 << 25
+
+type SvgImportOptions struct {
+	// Names the colors of shapes. Uses [main.DefaultPalette] if nil.
+	Palette *main.Palette
+	// If true, shapes keep the colors they're filled with, rather than being simplified to the nearest palette color.
+	PreserveColor bool
+	// If true, shapes simplified to white are kept, rather than being treated as the board.
+	AllowWhite bool
+}
+
+var DefaultSvgImportOptions = SvgImportOptions{}
+
+// Builds shapes from the filled path, polygon, polyline, rect, circle and ellipse elements of an SVG image,
+// as if they had been drawn on a board. Curves are broken up into straight lines for the shapes' paths,
+// but are also kept as the shapes' curves. Subpaths become holes where the element's fill rule leaves them unfilled.
+// Strokes, text, gradients and other elements are ignored.
+// If any of the drawing is left of or above the origin, all of it is moved right and down so none of it is.
+// Drawings that are too large or detailed to import fail with [ErrSvgTooLarge].
+func SvgDeserialize(r io.Reader, options *SvgImportOptions) (*main.BoardshapesData, error) {
+	if options == nil {
+		options = &DefaultSvgImportOptions
+	}
+	palette := main.DefaultPalette
+	if options.Palette != nil {
+		palette = *options.Palette
+	}
+	classifier := palette.Classifier()
+
+	data := &main.BoardshapesData{
+		Version: main.VERSION,
+		Palette: main.Palette{Entries: slices.Clone(palette.Entries)},
+	}
+
+	elements := make([]svgElement, 0)
+	decoder := xml.NewDecoder(r)
+	stack := []svgState{{transform: svgIdentity, fill: color.NRGBA{0, 0, 0, 255}, hasFill: true, opacity: 1}}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSvg, err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			state, err := stack[len(stack)-1].child(token)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, state)
+			if state.hidden || !state.hasFill {
+				continue
+			}
+
+			subpaths, err := svgElementSubpaths(token)
+			if err != nil {
+				return nil, err
+			}
+			for i := range subpaths {
+				subpaths[i].transform(state.transform)
+			}
+
+			fill := state.fill
+			fill.A = uint8(math.Round(float64(fill.A) * state.opacity))
+			elements = append(elements, svgElement{subpaths: subpaths, fill: fill, evenOdd: state.evenOdd})
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	// check before breaking up any curves, since huge ones would be broken up into huge numbers of points
+	points := 0.0
+	for _, element := range elements {
+		for _, sp := range element.subpaths {
+			points += sp.flattenedSize()
+		}
+	}
+	if !(points <= SVG_MAX_POINTS) {
+		return nil, fmt.Errorf("%w: paths have more than %d points", ErrSvgTooLarge, SVG_MAX_POINTS)
+	}
+
+	// shapes can't be left of or above the origin, so move the whole drawing rather than squashing what's there
+	minX, minY, maxX, maxY := 0.0, 0.0, 0.0, 0.0
+	for _, element := range elements {
+		for _, sp := range element.subpaths {
+			for _, p := range sp.flatten() {
+				minX, minY = min(minX, p.X), min(minY, p.Y)
+				maxX, maxY = max(maxX, p.X), max(maxY, p.Y)
+			}
+		}
+	}
+	// corners and vertices are stored as 16-bit unsigned integers
+	if !(maxX-math.Floor(minX) <= math.MaxUint16 && maxY-math.Floor(minY) <= math.MaxUint16) {
+		return nil, fmt.Errorf("%w: drawing is wider or taller than %d pixels", ErrSvgTooLarge, math.MaxUint16)
+	}
+	translation := svgTransform{1, 0, 0, 1, -math.Floor(minX), -math.Floor(minY)}
+
+	pixels := 0
+	for _, element := range elements {
+		for i := range element.subpaths {
+			element.subpaths[i].transform(translation)
+		}
+		for _, shape := range svgShapes(element.subpaths, element.evenOdd) {
+			shape.Number = len(data.Shapes)
+			shape.Color = element.fill
+			if !options.PreserveColor {
+				opaque := element.fill
+				opaque.A = 255
+				shape.Color = classifier.Classify(opaque)
+			}
+			if shape.Color == main.White && !options.AllowWhite {
+				continue
+			}
+			shape.ColorName = palette.Name(shape.Color)
+
+			bounds := shape.Bounds()
+			if pixels += bounds.Dx() * bounds.Dy(); pixels > SVG_MAX_PIXELS {
+				return nil, fmt.Errorf("%w: shape images have more than %d pixels", ErrSvgTooLarge, SVG_MAX_PIXELS)
+			}
+			shape.Image = rasterizeShape(shape)
+
+			data.Shapes = append(data.Shapes, shape)
+		}
+	}
+
+	return data, nil
+}
+
+// A filled element, waiting to be turned into shapes.
+type svgElement struct {
+	subpaths []svgSubpath
+	fill     color.NRGBA
+	evenOdd  bool
+}
+
+// Creates an image of the shape from its geometry, filled with its color,
+// for shapes that weren't made from an image.
+func rasterizeShape(shape main.ShapeData) image.Image {
+	mask := shape.Mask()
+	img := image.NewNRGBA(mask.Bounds())
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			if mask.AlphaAt(x, y).A >= 128 {
+				img.Set(x, y, shape.Color)
+			}
+		}
+	}
+	return img
+}
+
+// A 2D affine transform (a, b, c, d, e, f), the same as an SVG matrix.
+type svgTransform [6]float64
+
+var svgIdentity = svgTransform{1, 0, 0, 1, 0, 0}
+
+func (t svgTransform) apply(p svgPoint) svgPoint {
+	return svgPoint{t[0]*p.X + t[2]*p.Y + t[4], t[1]*p.X + t[3]*p.Y + t[5]}
+}
+
+// Returns the transform that applies other first, then t.
+func (t svgTransform) multiply(other svgTransform) svgTransform {
+	return svgTransform{
+		t[0]*other[0] + t[2]*other[1],
+		t[1]*other[0] + t[3]*other[1],
+		t[0]*other[2] + t[2]*other[3],
+		t[1]*other[2] + t[3]*other[3],
+		t[0]*other[4] + t[2]*other[5] + t[4],
+		t[1]*other[4] + t[3]*other[5] + t[5],
+	}
+}
+
+// The inherited properties of an element.
+type svgState struct {
+	transform svgTransform
+	fill      color.NRGBA
+	hasFill   bool
+	opacity   float64
+	hidden    bool
+	// the fill rule is nonzero unless this is set
+	evenOdd bool
+}
+
+// Returns the state of the element, which inherits from (and is transformed by) its parent.
+func (s svgState) child(element xml.StartElement) (svgState, error) {
+	switch element.Name.Local {
+	case "defs", "clipPath", "mask", "symbol", "pattern", "marker", "title", "desc", "metadata":
+		s.hidden = true
+	}
+
+	properties := make(map[string]string)
+	for _, attr := range element.Attr {
+		properties[attr.Name.Local] = attr.Value
+	}
+	// styles take precedence over attributes
+	for _, declaration := range strings.Split(properties["style"], ";") {
+		if name, value, ok := strings.Cut(declaration, ":"); ok {
+			properties[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	if element.Name.Local == "svg" {
+		viewBox, err := svgViewBox(properties)
+		if err != nil {
+			return s, err
+		}
+		s.transform = s.transform.multiply(viewBox)
+	}
+	if value, ok := properties["transform"]; ok {
+		transform, err := parseSvgTransform(value)
+		if err != nil {
+			return s, err
+		}
+		s.transform = s.transform.multiply(transform)
+	}
+
+	if value, ok := properties["fill"]; ok {
+		if value == "none" || value == "transparent" {
+			s.hasFill = false
+		} else if fill, ok := parseSvgColor(value); ok {
+			s.fill, s.hasFill = fill, true
+		}
+	}
+	switch properties["fill-rule"] {
+	case "evenodd":
+		s.evenOdd = true
+	case "nonzero":
+		s.evenOdd = false
+	}
+	if value, ok := properties["fill-opacity"]; ok {
+		if opacity, err := strconv.ParseFloat(value, 64); err == nil {
+			s.fill.A = uint8(math.Round(255 * max(0, min(1, opacity))))
+		}
+	}
+	if value, ok := properties["opacity"]; ok {
+		if opacity, err := strconv.ParseFloat(value, 64); err == nil {
+			s.opacity *= max(0, min(1, opacity))
+		}
+	}
+	if properties["display"] == "none" || properties["visibility"] == "hidden" {
+		s.hidden = true
+	}
+
+	return s, nil
+}
+
+// Returns the transform from the svg element's view box to its width and height.
+func svgViewBox(properties map[string]string) (svgTransform, error) {
+	values, ok := properties["viewBox"]
+	if !ok {
+		return svgIdentity, nil
+	}
+	numbers, err := parseSvgNumbers(values)
+	if err != nil || len(numbers) != 4 {
+		return svgIdentity, fmt.Errorf("%w: bad viewBox %q", ErrInvalidSvg, values)
+	}
+	minX, minY, width, height := numbers[0], numbers[1], numbers[2], numbers[3]
+
+	scaleX, scaleY := 1.0, 1.0
+	if w, ok := parseSvgLength(properties["width"]); ok && width > 0 {
+		scaleX = w / width
+	}
+	if h, ok := parseSvgLength(properties["height"]); ok && height > 0 {
+		scaleY = h / height
+	}
+	return svgTransform{scaleX, 0, 0, scaleY, -minX * scaleX, -minY * scaleY}, nil
+}
+
+func parseSvgTransform(value string) (svgTransform, error) {
+	result := svgIdentity
+	rest := value
+	for strings.TrimSpace(rest) != "" {
+		name, after, ok := strings.Cut(rest, "(")
+		if !ok {
+			return result, fmt.Errorf("%w: bad transform %q", ErrInvalidSvg, value)
+		}
+		arguments, after, ok := strings.Cut(after, ")")
+		if !ok {
+			return result, fmt.Errorf("%w: bad transform %q", ErrInvalidSvg, value)
+		}
+		rest = strings.TrimLeft(after, " \t\r\n,")
+
+		a, err := parseSvgNumbers(arguments)
+		if err != nil || len(a) == 0 {
+			return result, fmt.Errorf("%w: bad transform %q", ErrInvalidSvg, value)
+		}
+		var t svgTransform
+		switch strings.TrimSpace(name) {
+		case "matrix":
+			if len(a) != 6 {
+				return result, fmt.Errorf("%w: bad transform %q", ErrInvalidSvg, value)
+			}
+			t = svgTransform(a)
+		case "translate":
+			t = svgTransform{1, 0, 0, 1, a[0], 0}
+			if len(a) > 1 {
+				t[5] = a[1]
+			}
+		case "scale":
+			t = svgTransform{a[0], 0, 0, a[0], 0, 0}
+			if len(a) > 1 {
+				t[3] = a[1]
+			}
+		case "rotate":
+			sin, cos := math.Sincos(a[0] * math.Pi / 180)
+			t = svgTransform{cos, sin, -sin, cos, 0, 0}
+			if len(a) == 3 {
+				// rotate around (a[1], a[2])
+				t = svgTransform{1, 0, 0, 1, a[1], a[2]}.multiply(t).multiply(svgTransform{1, 0, 0, 1, -a[1], -a[2]})
+			}
+		case "skewX":
+			t = svgTransform{1, 0, math.Tan(a[0] * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = svgTransform{1, math.Tan(a[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return result, fmt.Errorf("%w: bad transform %q", ErrInvalidSvg, value)
+		}
+		result = result.multiply(t)
+	}
+	return result, nil
+}
+
+func parseSvgColor(value string) (color.NRGBA, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case strings.HasPrefix(value, "#"):
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 255}, true
+	case strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")"):
+		channels := strings.Split(value[4:len(value)-1], ",")
+		if len(channels) != 3 {
+			return color.NRGBA{}, false
+		}
+		var c [3]uint8
+		for i, channel := range channels {
+			channel = strings.TrimSpace(channel)
+			scale := 1.0
+			if strings.HasSuffix(channel, "%") {
+				channel, scale = channel[:len(channel)-1], 2.55
+			}
+			v, err := strconv.ParseFloat(channel, 64)
+			if err != nil {
+				return color.NRGBA{}, false
+			}
+			c[i] = uint8(math.Round(max(0, min(255, v*scale))))
+		}
+		return color.NRGBA{c[0], c[1], c[2], 255}, true
+	default:
+		named, ok := colornames.Map[value]
+		return color.NRGBA{named.R, named.G, named.B, 255}, ok
+	}
+}
+
+// Parses a length in pixels (or without units).
+func parseSvgLength(value string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	return v, err == nil
+}
+
+// Parses numbers separated by whitespace and/or commas.
+func parseSvgNumbers(value string) ([]float64, error) {
+	scanner := svgScanner{s: value}
+	numbers := make([]float64, 0)
+	for !scanner.done() {
+		n, err := scanner.number()
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+type svgPoint struct {
+	X, Y float64
+}
+
+// A cubic Bezier curve that continues from where the previous one ended.
+type svgSegment struct {
+	control1, control2, end svgPoint
+	// straight lines are kept as curves too, but don't need to be broken up
+	straight bool
+}
+
+// A closed outline, made of curves.
+type svgSubpath struct {
+	start    svgPoint
+	segments []svgSegment
+}
+
+func (sp *svgSubpath) transform(t svgTransform) {
+	sp.start = t.apply(sp.start)
+	for i, s := range sp.segments {
+		sp.segments[i] = svgSegment{t.apply(s.control1), t.apply(s.control2), t.apply(s.end), s.straight}
+	}
+}
+
+func (sp svgSubpath) curved() bool {
+	return slices.ContainsFunc(sp.segments, func(s svgSegment) bool { return !s.straight })
+}
+
+// Returns the subpath running the other way.
+func (sp svgSubpath) reversed() svgSubpath {
+	if len(sp.segments) == 0 {
+		return sp
+	}
+	result := svgSubpath{start: sp.segments[len(sp.segments)-1].end, segments: make([]svgSegment, len(sp.segments))}
+	for i, s := range sp.segments {
+		start := sp.start
+		if i > 0 {
+			start = sp.segments[i-1].end
+		}
+		result.segments[len(sp.segments)-1-i] = svgSegment{s.control2, s.control1, start, s.straight}
+	}
+	return result
+}
+
+// How many steps the curve from start is broken up into, which is more for longer curves.
+// It's a float, since huge curves can have more steps than fit in an int.
+func (s svgSegment) steps(start svgPoint) float64 {
+	if s.straight {
+		return 1
+	}
+	length := math.Hypot(s.control1.X-start.X, s.control1.Y-start.Y) +
+		math.Hypot(s.control2.X-s.control1.X, s.control2.Y-s.control1.Y) +
+		math.Hypot(s.end.X-s.control2.X, s.end.Y-s.control2.Y)
+	return max(2, math.Ceil(length/SVG_FLATTENING_STEP))
+}
+
+// How many points [svgSubpath.flatten] returns.
+func (sp svgSubpath) flattenedSize() float64 {
+	size := 1.0
+	previous := sp.start
+	for _, s := range sp.segments {
+		size += s.steps(previous)
+		previous = s.end
+	}
+	return size
+}
+
+// Breaks the curves up into straight lines.
+func (sp svgSubpath) flatten() []svgPoint {
+	points := []svgPoint{sp.start}
+	previous := sp.start
+	for _, s := range sp.segments {
+		if !s.straight {
+			steps := int(s.steps(previous))
+			for step := 1; step < steps; step++ {
+				t := float64(step) / float64(steps)
+				u := 1 - t
+				points = append(points, svgPoint{
+					u*u*u*previous.X + 3*u*u*t*s.control1.X + 3*u*t*t*s.control2.X + t*t*t*s.end.X,
+					u*u*u*previous.Y + 3*u*u*t*s.control1.Y + 3*u*t*t*s.control2.Y + t*t*t*s.end.Y,
+				})
+			}
+		}
+		points = append(points, s.end)
+		previous = s.end
+	}
+	return points
+}
+
+// Returns twice the signed area of the polygon. Positive means it runs clockwise on screen.
+func svgSignedArea(points []svgPoint) float64 {
+	area := 0.0
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area
+}
+
+func svgContains(polygon []svgPoint, p svgPoint) bool {
+	inside := false
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Whether most of the outline is inside the polygon. Outlines traced from images touch the outlines around them,
+// and can even cross them a little once they're simplified, so a single point can't tell which they're inside.
+// Points on the polygon's edges don't count either way.
+func svgContainsOutline(polygon []svgPoint, outline []svgPoint) bool {
+	inside, outside := 0, 0
+	for _, p := range outline {
+		switch {
+		case svgOnEdge(polygon, p):
+		case svgContains(polygon, p):
+			inside++
+		default:
+			outside++
+		}
+	}
+	return inside >= outside
+}
+
+func svgOnEdge(polygon []svgPoint, p svgPoint) bool {
+	const tolerance = 1e-6
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		cross := (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+		if math.Abs(cross) <= tolerance*max(1, math.Hypot(b.X-a.X, b.Y-a.Y)) &&
+			p.X >= min(a.X, b.X)-tolerance && p.X <= max(a.X, b.X)+tolerance &&
+			p.Y >= min(a.Y, b.Y)-tolerance && p.Y <= max(a.Y, b.Y)+tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// Turns the element into closed subpaths, or none if it isn't a supported element.
+func svgElementSubpaths(element xml.StartElement) ([]svgSubpath, error) {
+	attrs := make(map[string]string)
+	for _, attr := range element.Attr {
+		attrs[attr.Name.Local] = attr.Value
+	}
+	number := func(name string) float64 {
+		v, _ := parseSvgLength(attrs[name])
+		return v
+	}
+
+	switch element.Name.Local {
+	case "path":
+		return parseSvgPathData(attrs["d"])
+	case "polygon", "polyline":
+		numbers, err := parseSvgNumbers(attrs["points"])
+		if err != nil || len(numbers) < 6 {
+			return nil, nil
+		}
+		subpath := svgSubpath{start: svgPoint{numbers[0], numbers[1]}}
+		previous := subpath.start
+		for i := 2; i+1 < len(numbers); i += 2 {
+			p := svgPoint{numbers[i], numbers[i+1]}
+			subpath.segments = append(subpath.segments, svgLine(previous, p))
+			previous = p
+		}
+		subpath.segments = append(subpath.segments, svgLine(previous, subpath.start))
+		return []svgSubpath{subpath}, nil
+	case "rect":
+		x, y, w, h := number("x"), number("y"), number("width"), number("height")
+		if w <= 0 || h <= 0 {
+			return nil, nil
+		}
+		corners := []svgPoint{{x + w, y}, {x + w, y + h}, {x, y + h}, {x, y}}
+		subpath := svgSubpath{start: svgPoint{x, y}}
+		previous := subpath.start
+		for _, c := range corners {
+			subpath.segments = append(subpath.segments, svgLine(previous, c))
+			previous = c
+		}
+		return []svgSubpath{subpath}, nil
+	case "circle":
+		r := number("r")
+		return svgEllipse(number("cx"), number("cy"), r, r), nil
+	case "ellipse":
+		return svgEllipse(number("cx"), number("cy"), number("rx"), number("ry")), nil
+	}
+	return nil, nil
+}
+
+func svgLine(from, to svgPoint) svgSegment {
+	return svgSegment{
+		svgPoint{from.X + (to.X-from.X)/3, from.Y + (to.Y-from.Y)/3},
+		svgPoint{from.X + (to.X-from.X)*2/3, from.Y + (to.Y-from.Y)*2/3},
+		to,
+		true,
+	}
+}
+
+func svgEllipse(cx, cy, rx, ry float64) []svgSubpath {
+	if rx <= 0 || ry <= 0 {
+		return nil
+	}
+	subpath := svgSubpath{start: svgPoint{cx + rx, cy}}
+	subpath.segments = svgArcSegments(cx, cy, rx, ry, 0, 0, 2*math.Pi)
+	return []svgSubpath{subpath}
+}
+
+// Approximates the elliptical arc with curves, each covering at most a quarter turn.
+func svgArcSegments(cx, cy, rx, ry, phi, theta, delta float64) []svgSegment {
+	sinPhi, cosPhi := math.Sincos(phi)
+	at := func(theta float64) (p, derivative svgPoint) {
+		sin, cos := math.Sincos(theta)
+		x, y := rx*cos, ry*sin
+		dx, dy := -rx*sin, ry*cos
+		return svgPoint{cx + cosPhi*x - sinPhi*y, cy + sinPhi*x + cosPhi*y},
+			svgPoint{cosPhi*dx - sinPhi*dy, sinPhi*dx + cosPhi*dy}
+	}
+
+	n := max(1, int(math.Ceil(math.Abs(delta)/(math.Pi/2)-1e-9)))
+	step := delta / float64(n)
+	alpha := 4.0 / 3 * math.Tan(step/4)
+	segments := make([]svgSegment, n)
+	for i := range n {
+		p1, d1 := at(theta + step*float64(i))
+		p2, d2 := at(theta + step*float64(i+1))
+		segments[i] = svgSegment{
+			svgPoint{p1.X + alpha*d1.X, p1.Y + alpha*d1.Y},
+			svgPoint{p2.X - alpha*d2.X, p2.Y - alpha*d2.Y},
+			p2,
+			false,
+		}
+	}
+	return segments
+}
+
+// Converts an arc in SVG's endpoint form to curves, following the SVG spec's implementation notes.
+func svgArc(from svgPoint, rx, ry, angle float64, largeArc, sweep bool, to svgPoint) []svgSegment {
+	if from == to {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []svgSegment{svgLine(from, to)}
+	}
+
+	phi := angle * math.Pi / 180
+	sinPhi, cosPhi := math.Sincos(phi)
+	dx, dy := (from.X-to.X)/2, (from.Y-to.Y)/2
+	x1, y1 := cosPhi*dx+sinPhi*dy, -sinPhi*dx+cosPhi*dy
+
+	// scale up radii that are too small to reach
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+
+	numerator := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	denominator := rx*rx*y1*y1 + ry*ry*x1*x1
+	coefficient := math.Sqrt(max(0, numerator/denominator))
+	if largeArc == sweep {
+		coefficient = -coefficient
+	}
+	cx1, cy1 := coefficient*rx*y1/ry, -coefficient*ry*x1/rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (from.X+to.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (from.Y+to.Y)/2
+
+	vectorAngle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := vectorAngle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := vectorAngle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	segments := svgArcSegments(cx, cy, rx, ry, phi, theta, delta)
+	// land exactly on the end point
+	segments[len(segments)-1].end = to
+	return segments
+}
+
+func parseSvgPathData(d string) ([]svgSubpath, error) {
+	subpaths := make([]svgSubpath, 0)
+	// the index of the subpath being drawn, or -1 if the last one was closed
+	current := -1
+	var position, lastControl svgPoint
+	var command, previousCommand byte
+
+	invalid := func() error {
+		return fmt.Errorf("%w: bad path data %q", ErrInvalidSvg, d)
+	}
+	addSegment := func(s svgSegment) {
+		if current == -1 {
+			// drawing continues from where the last subpath was closed
+			subpaths = append(subpaths, svgSubpath{start: position})
+			current = len(subpaths) - 1
+		}
+		subpaths[current].segments = append(subpaths[current].segments, s)
+		position = s.end
+	}
+
+	scanner := svgScanner{s: d}
+	for !scanner.done() {
+		if c := scanner.peek(); c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			command = c
+			scanner.i++
+		} else if command == 0 {
+			return nil, invalid()
+		} else if command == 'M' || command == 'm' {
+			// coordinates after a move are lines
+			command -= 'M' - 'L'
+		}
+
+		relative := command >= 'a'
+		offset := func(p svgPoint) svgPoint {
+			if relative {
+				return svgPoint{p.X + position.X, p.Y + position.Y}
+			}
+			return p
+		}
+		numbers := func(n int) ([]float64, error) {
+			values := make([]float64, n)
+			for i := range values {
+				v, err := scanner.number()
+				if err != nil {
+					return nil, invalid()
+				}
+				values[i] = v
+			}
+			return values, nil
+		}
+		// reflects the last control point, if the previous command had one
+		reflected := func(curveCommands string) svgPoint {
+			if strings.IndexByte(curveCommands, previousCommand|0x20) == -1 {
+				return position
+			}
+			return svgPoint{2*position.X - lastControl.X, 2*position.Y - lastControl.Y}
+		}
+
+		switch command | 0x20 {
+		case 'm':
+			v, err := numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			position = offset(svgPoint{v[0], v[1]})
+			subpaths = append(subpaths, svgSubpath{start: position})
+			current = len(subpaths) - 1
+		case 'z':
+			if current != -1 {
+				start := subpaths[current].start
+				if position != start {
+					addSegment(svgLine(position, start))
+				}
+				position = start
+				current = -1
+			}
+		case 'l':
+			v, err := numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			addSegment(svgLine(position, offset(svgPoint{v[0], v[1]})))
+		case 'h', 'v':
+			v, err := numbers(1)
+			if err != nil {
+				return nil, err
+			}
+			to := position
+			if command|0x20 == 'h' {
+				to.X = v[0]
+				if relative {
+					to.X += position.X
+				}
+			} else {
+				to.Y = v[0]
+				if relative {
+					to.Y += position.Y
+				}
+			}
+			addSegment(svgLine(position, to))
+		case 'c', 's':
+			var control1 svgPoint
+			if command|0x20 == 'c' {
+				v, err := numbers(2)
+				if err != nil {
+					return nil, err
+				}
+				control1 = offset(svgPoint{v[0], v[1]})
+			} else {
+				control1 = reflected("cs")
+			}
+			v, err := numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			control2, end := offset(svgPoint{v[0], v[1]}), offset(svgPoint{v[2], v[3]})
+			addSegment(svgSegment{control1, control2, end, false})
+			lastControl = control2
+		case 'q', 't':
+			var control svgPoint
+			if command|0x20 == 'q' {
+				v, err := numbers(2)
+				if err != nil {
+					return nil, err
+				}
+				control = offset(svgPoint{v[0], v[1]})
+			} else {
+				control = reflected("qt")
+			}
+			v, err := numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			from, end := position, offset(svgPoint{v[0], v[1]})
+			// a quadratic curve is a cubic curve with its control points two thirds of the way to the quadratic's
+			addSegment(svgSegment{
+				svgPoint{from.X + (control.X-from.X)*2/3, from.Y + (control.Y-from.Y)*2/3},
+				svgPoint{end.X + (control.X-end.X)*2/3, end.Y + (control.Y-end.Y)*2/3},
+				end,
+				false,
+			})
+			lastControl = control
+		case 'a':
+			v, err := numbers(3)
+			if err != nil {
+				return nil, err
+			}
+			largeArc, err1 := scanner.flag()
+			sweep, err2 := scanner.flag()
+			end, err3 := numbers(2)
+			if err1 != nil || err2 != nil || err3 != nil {
+				return nil, invalid()
+			}
+			for _, s := range svgArc(position, v[0], v[1], v[2], largeArc, sweep, offset(svgPoint{end[0], end[1]})) {
+				addSegment(s)
+			}
+		default:
+			return nil, invalid()
+		}
+		previousCommand = command
+	}
+
+	// every subpath is filled as if it were closed
+	for i, sp := range subpaths {
+		if len(sp.segments) > 0 && sp.segments[len(sp.segments)-1].end != sp.start {
+			subpaths[i].segments = append(sp.segments, svgLine(sp.segments[len(sp.segments)-1].end, sp.start))
+		}
+	}
+	return subpaths, nil
+}
+
+type svgScanner struct {
+	s string
+	i int
+}
+
+func (sc *svgScanner) skipSeparators() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) != -1 {
+		sc.i++
+	}
+}
+
+func (sc *svgScanner) done() bool {
+	sc.skipSeparators()
+	return sc.i >= len(sc.s)
+}
+
+func (sc *svgScanner) peek() byte {
+	sc.skipSeparators()
+	if sc.i >= len(sc.s) {
+		return 0
+	}
+	return sc.s[sc.i]
+}
+
+func (sc *svgScanner) number() (float64, error) {
+	sc.skipSeparators()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+		sc.i++
+	}
+	dot, exponent := false, false
+	for sc.i < len(sc.s) {
+		c := sc.s[sc.i]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && !dot && !exponent:
+			dot = true
+		case (c == 'e' || c == 'E') && !exponent && sc.i > start:
+			exponent = true
+			if sc.i+1 < len(sc.s) && (sc.s[sc.i+1] == '+' || sc.s[sc.i+1] == '-') {
+				sc.i++
+			}
+		default:
+			// numbers can run straight into the next one, like "1.5.5" or "1-2"
+			return strconv.ParseFloat(sc.s[start:sc.i], 64)
+		}
+		sc.i++
+	}
+	return strconv.ParseFloat(sc.s[start:sc.i], 64)
+}
+
+// Arc flags are a single digit, which doesn't have to be separated from what comes after it.
+func (sc *svgScanner) flag() (bool, error) {
+	switch sc.peek() {
+	case '0':
+		sc.i++
+		return false, nil
+	case '1':
+		sc.i++
+		return true, nil
+	}
+	return false, ErrInvalidSvg
+}
+
+// Turns the subpaths of an element into shapes, following the element's fill rule. Subpaths where the fill starts
+// are the outlines of separate shapes, subpaths where it stops are holes in the shape around them,
+// and the rest don't change what's filled and are left out.
+func svgShapes(subpaths []svgSubpath, evenOdd bool) []main.ShapeData {
+	type outline struct {
+		subpath svgSubpath
+		points  []svgPoint
+		holes   []int
+		isShape bool
+		// the innermost outline around this one, or -1
+		parent int
+		// the winding number inside this outline, or how many outlines it's inside (including itself) for evenodd
+		winding int
+	}
+	outlines := make([]outline, 0, len(subpaths))
+	for _, sp := range subpaths {
+		points := sp.flatten()
+		if len(points) >= 3 && math.Abs(svgSignedArea(points)) > 1 {
+			outlines = append(outlines, outline{subpath: sp, points: points})
+		}
+	}
+	// larger subpaths can contain smaller ones, but not the other way around
+	slices.SortStableFunc(outlines, func(a, b outline) int {
+		return cmp.Compare(math.Abs(svgSignedArea(b.points)), math.Abs(svgSignedArea(a.points)))
+	})
+
+	filled := func(winding int) bool {
+		if evenOdd {
+			return winding%2 != 0
+		}
+		return winding != 0
+	}
+	for i := range outlines {
+		o := &outlines[i]
+		o.parent = -1
+		for j := range i {
+			if svgContainsOutline(outlines[j].points, o.points) {
+				o.parent = j
+			}
+		}
+
+		outside := 0
+		if o.parent >= 0 {
+			outside = outlines[o.parent].winding
+		}
+		o.winding = outside + 1
+		if !evenOdd && svgSignedArea(o.points) < 0 {
+			o.winding = outside - 1
+		}
+
+		switch {
+		case filled(o.winding) && !filled(outside):
+			o.isShape = true
+		case !filled(o.winding) && filled(outside):
+			// the outlines between this one and the shape it's a hole in don't change what's filled
+			shape := o.parent
+			for !outlines[shape].isShape {
+				shape = outlines[shape].parent
+			}
+			outlines[shape].holes = append(outlines[shape].holes, i)
+		}
+	}
+
+	shapes := make([]main.ShapeData, 0)
+	for _, o := range outlines {
+		if !o.isShape {
+			continue
+		}
+
+		minX, minY := math.Inf(1), math.Inf(1)
+		for _, p := range o.points {
+			minX, minY = min(minX, p.X), min(minY, p.Y)
+		}
+		corner := svgPoint{math.Floor(minX), math.Floor(minY)}
+
+		// outlines run clockwise and holes run counter-clockwise
+		orient := func(sp svgSubpath, points []svgPoint, clockwise bool) (svgSubpath, []svgPoint) {
+			if (svgSignedArea(points) > 0) != clockwise {
+				points = slices.Clone(points)
+				slices.Reverse(points)
+				return sp.reversed(), points
+			}
+			return sp, points
+		}
+
+		outlineSubpath, outlinePoints := orient(o.subpath, o.points, true)
+		shape := main.ShapeData{
+			CornerX: int(corner.X),
+			CornerY: int(corner.Y),
+			Path:    svgVertices(outlinePoints, corner),
+		}
+		curved := outlineSubpath.curved()
+		holeSubpaths := make([]svgSubpath, 0, len(o.holes))
+		for _, i := range o.holes {
+			holeSubpath, holePoints := orient(outlines[i].subpath, outlines[i].points, false)
+			if hole := svgVertices(holePoints, corner); len(hole) >= 3 {
+				shape.Holes = append(shape.Holes, hole)
+				holeSubpaths = append(holeSubpaths, holeSubpath)
+				curved = curved || holeSubpath.curved()
+			}
+		}
+		if len(shape.Path) < 3 {
+			continue
+		}
+
+		if curved {
+			shape.Curves = svgCurves(outlineSubpath, corner)
+			for _, sp := range holeSubpaths {
+				shape.HoleCurves = append(shape.HoleCurves, svgCurves(sp, corner))
+			}
+		}
+
+		shapes = append(shapes, shape)
+	}
+	return shapes
+}
+
+// Rounds the points to vertices relative to the corner, leaving out repeated vertices.
+func svgVertices(points []svgPoint, corner svgPoint) []main.Vertex {
+	vertices := make([]main.Vertex, 0, len(points))
+	for _, p := range points {
+		v := main.Vertex{
+			X: uint16(max(0, min(math.MaxUint16, math.Round(p.X-corner.X)))),
+			Y: uint16(max(0, min(math.MaxUint16, math.Round(p.Y-corner.Y)))),
+		}
+		if len(vertices) == 0 || vertices[len(vertices)-1] != v {
+			vertices = append(vertices, v)
+		}
+	}
+	for len(vertices) > 1 && vertices[len(vertices)-1] == vertices[0] {
+		vertices = vertices[:len(vertices)-1]
+	}
+	return vertices
+}
+
+func svgCurves(sp svgSubpath, corner svgPoint) []main.CubicBezier {
+	relative := func(p svgPoint) main.CurvePoint {
+		return main.CurvePoint{X: float32(p.X - corner.X), Y: float32(p.Y - corner.Y)}
+	}
+	curves := make([]main.CubicBezier, len(sp.segments))
+	start := sp.start
+	for i, s := range sp.segments {
+		curves[i] = main.CubicBezier{
+			Start:    relative(start),
+			Control1: relative(s.control1),
+			Control2: relative(s.control2),
+			End:      relative(s.end),
+		}
+		start = s.end
+	}
+	return curves
+}
@@ -0,0 +1,200 @@
+package serialization
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+const testSvg = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 400 200">
+  <defs>
+    <rect id="hidden" width="50" height="50" fill="red"/>
+  </defs>
+  <rect width="400" height="200" fill="white"/>
+  <rect x="20" y="20" width="100" height="60" fill="#e01010"/>
+  <g fill="blue" transform="translate(200 0)">
+    <circle cx="50" cy="100" r="40"/>
+    <path fill-rule="evenodd" d="M100,40 h80 v120 h-80 z M120,60 v80 h40 v-80 z"/>
+  </g>
+  <ellipse cx="60" cy="150" rx="40" ry="20" style="fill: rgb(0, 200, 0)"/>
+  <path d="M0 0 L10 10" fill="none" stroke="black"/>
+</svg>`
+
+func TestSvgDeserialize(t *testing.T) {
+	data, err := SvgDeserialize(strings.NewReader(testSvg), nil)
+	if err != nil {
+		t.Fatalf("SvgDeserialize() error = %v", err)
+	}
+
+	names := make([]string, len(data.Shapes))
+	for i, shape := range data.Shapes {
+		names[i] = shape.ColorName
+	}
+	if want := []string{"Red", "Blue", "Blue", "Green"}; !slices.Equal(names, want) {
+		t.Fatalf("SvgDeserialize() shape colors = %v, want %v", names, want)
+	}
+
+	// the view box halves everything
+	rect := data.Shapes[0]
+	if rect.CornerX != 10 || rect.CornerY != 10 || !slices.Equal(rect.Path, []main.Vertex{{X: 0, Y: 0}, {X: 50, Y: 0}, {X: 50, Y: 30}, {X: 0, Y: 30}}) {
+		t.Errorf("SvgDeserialize() rect at (%d, %d) with path %v, want a 50x30 rectangle at (10, 10)", rect.CornerX, rect.CornerY, rect.Path)
+	}
+	if rect.Curves != nil {
+		t.Errorf("SvgDeserialize() rect has curves, want none")
+	}
+
+	circle := data.Shapes[1]
+	if circle.CornerX != 105 || circle.CornerY != 30 || len(circle.Curves) != 4 {
+		t.Errorf("SvgDeserialize() circle at (%d, %d) with %d curves, want (105, 30) with 4", circle.CornerX, circle.CornerY, len(circle.Curves))
+	}
+
+	frame := data.Shapes[2]
+	if len(frame.Holes) != 1 {
+		t.Fatalf("SvgDeserialize() frame has %d holes, want 1", len(frame.Holes))
+	}
+	filled := 0
+	bds := frame.Image.Bounds()
+	for y := bds.Min.Y; y < bds.Max.Y; y++ {
+		for x := bds.Min.X; x < bds.Max.X; x++ {
+			if _, _, _, a := frame.Image.At(x, y).RGBA(); a > 0 {
+				filled++
+			}
+		}
+	}
+	// 40x60 minus 20x40
+	if filled < 1500 || filled > 1700 {
+		t.Errorf("SvgDeserialize() frame image has %d pixels filled, want about 1600", filled)
+	}
+}
+
+func TestSvgRoundTrip(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{})
+	w := &bytes.Buffer{}
+	if err := SvgSerialize(w, data, nil); err != nil {
+		t.Fatalf("SvgSerialize() error = %v", err)
+	}
+
+	result, err := SvgDeserialize(w, &SvgImportOptions{Palette: &data.Palette})
+	if err != nil {
+		t.Fatalf("SvgDeserialize() error = %v", err)
+	}
+	if len(result.Shapes) != len(data.Shapes) {
+		t.Fatalf("SvgDeserialize() returned %d shapes, want %d", len(result.Shapes), len(data.Shapes))
+	}
+	// the corners of shapes can be further out than their paths, so compare where the paths are in the image.
+	// SVG paths don't keep repeated vertices.
+	absolute := func(shape main.ShapeData) []main.Vertex {
+		path := make([]main.Vertex, len(shape.Path))
+		for i, v := range shape.Path {
+			path[i] = main.Vertex{X: v.X + uint16(shape.CornerX), Y: v.Y + uint16(shape.CornerY)}
+		}
+		path = slices.Compact(path)
+		for len(path) > 1 && path[len(path)-1] == path[0] {
+			path = path[:len(path)-1]
+		}
+		return path
+	}
+	// shapes are grouped by color, so they don't come back in the same order
+	for _, shape := range result.Shapes {
+		found := slices.ContainsFunc(data.Shapes, func(original main.ShapeData) bool {
+			return original.ColorName == shape.ColorName &&
+				slices.Equal(absolute(original), absolute(shape)) &&
+				len(original.Holes) == len(shape.Holes)
+		})
+		if !found {
+			t.Errorf("SvgDeserialize() shape %d (%s at %d, %d) doesn't match any original shape",
+				shape.Number, shape.ColorName, shape.CornerX, shape.CornerY)
+		}
+	}
+}
+
+func TestSvgDeserialize_NegativeCoordinates(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg">
+  <rect x="-20" y="-10" width="30" height="20"/>
+  <rect x="40" y="40" width="10" height="10"/>
+</svg>`
+	data, err := SvgDeserialize(strings.NewReader(svg), nil)
+	if err != nil {
+		t.Fatalf("SvgDeserialize() error = %v", err)
+	}
+	if len(data.Shapes) != 2 {
+		t.Fatalf("SvgDeserialize() returned %d shapes, want 2", len(data.Shapes))
+	}
+
+	// everything moves right 20 and down 10, keeping its size
+	want := []main.Vertex{{X: 0, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 20}, {X: 0, Y: 20}}
+	if shape := data.Shapes[0]; shape.CornerX != 0 || shape.CornerY != 0 || !slices.Equal(shape.Path, want) {
+		t.Errorf("SvgDeserialize() first rect at (%d, %d) with path %v, want %v at (0, 0)", shape.CornerX, shape.CornerY, shape.Path, want)
+	}
+	if shape := data.Shapes[1]; shape.CornerX != 60 || shape.CornerY != 50 {
+		t.Errorf("SvgDeserialize() second rect at (%d, %d), want (60, 50)", shape.CornerX, shape.CornerY)
+	}
+}
+
+func TestSvgDeserialize_FillRule(t *testing.T) {
+	// an outer square, with an inner square running the same way or the other way
+	sameWay := "M0,0 h100 v100 h-100 z M20,20 h60 v60 h-60 z"
+	otherWay := "M0,0 h100 v100 h-100 z M20,20 v60 h60 v-60 z"
+	tests := []struct {
+		name  string
+		attrs string
+		holes int
+	}{
+		{"nonzero by default, same way", `d="` + sameWay + `"`, 0},
+		{"nonzero, same way", `fill-rule="nonzero" d="` + sameWay + `"`, 0},
+		{"nonzero, other way", `fill-rule="nonzero" d="` + otherWay + `"`, 1},
+		{"evenodd, same way", `fill-rule="evenodd" d="` + sameWay + `"`, 1},
+		{"evenodd, other way", `fill-rule="evenodd" d="` + otherWay + `"`, 1},
+		{"inherited evenodd", `style="fill-rule: evenodd" d="` + sameWay + `"`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svg := `<svg xmlns="http://www.w3.org/2000/svg"><path ` + tt.attrs + `/></svg>`
+			data, err := SvgDeserialize(strings.NewReader(svg), nil)
+			if err != nil {
+				t.Fatalf("SvgDeserialize() error = %v", err)
+			}
+			if len(data.Shapes) != 1 {
+				t.Fatalf("SvgDeserialize() returned %d shapes, want 1", len(data.Shapes))
+			}
+			if holes := len(data.Shapes[0].Holes); holes != tt.holes {
+				t.Errorf("SvgDeserialize() shape has %d holes, want %d", holes, tt.holes)
+			}
+		})
+	}
+
+	// with nonzero, a subpath that's inside a hole and runs the way the outline does is filled again
+	svg := `<svg xmlns="http://www.w3.org/2000/svg"><path d="M0,0 h100 v100 h-100 z M10,10 v80 h80 v-80 z M30,30 h40 v40 h-40 z"/></svg>`
+	data, err := SvgDeserialize(strings.NewReader(svg), nil)
+	if err != nil {
+		t.Fatalf("SvgDeserialize() error = %v", err)
+	}
+	if len(data.Shapes) != 2 || len(data.Shapes[0].Holes) != 1 || len(data.Shapes[1].Holes) != 0 {
+		t.Errorf("SvgDeserialize() returned %d shapes, want a frame with 1 hole and a square in it", len(data.Shapes))
+	}
+}
+
+func TestSvgDeserialize_TooLarge(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements string
+	}{
+		{"curve with too many points", `<path d="M0,0 C1e9,0 1e9,1e9 0,1e9 z"/>`},
+		{"wider than a corner can be", `<rect x="60000" width="10000" height="10"/>`},
+		{"taller than a vertex can be", `<rect width="10" height="70000"/>`},
+		{"shapes with too many pixels", strings.Repeat(`<rect width="6000" height="6000"/>`, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svg := `<svg xmlns="http://www.w3.org/2000/svg">` + tt.elements + `</svg>`
+			if _, err := SvgDeserialize(strings.NewReader(svg), nil); !errors.Is(err, ErrSvgTooLarge) {
+				t.Errorf("SvgDeserialize() error = %v, want %v", err, ErrSvgTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+func TestSvgSerialize(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    *main.BoardshapesData
+		options *SvgOptions
+	}{
+		{
+			name: "lub",
+			data: main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{}),
+		},
+		{
+			name:    "curves and images",
+			data:    main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{ExtractCenterlines: true, CurveTolerance: 1.5}),
+			options: &SvgOptions{EmbedImages: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if err := SvgSerialize(w, tt.data, tt.options); err != nil {
+				t.Fatalf("SvgSerialize() error = %v", err)
+			}
+
+			// every shape should be drawn once, inside a layer for its color
+			shapes, images := 0, 0
+			layers := make(map[string]bool)
+			decoder := xml.NewDecoder(w)
+			for {
+				token, err := decoder.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("SvgSerialize() wrote invalid XML: %v", err)
+				}
+				if start, ok := token.(xml.StartElement); ok {
+					for _, attr := range start.Attr {
+						if attr.Name.Local == "id" && strings.HasPrefix(attr.Value, "shape-") {
+							shapes++
+						}
+						if attr.Name.Local == "id" && strings.HasPrefix(attr.Value, "image-") {
+							images++
+						}
+						if attr.Name.Local == "label" {
+							layers[attr.Value] = true
+						}
+					}
+				}
+			}
+
+			if shapes != len(tt.data.Shapes) {
+				t.Errorf("SvgSerialize() wrote %d shapes, want %d", shapes, len(tt.data.Shapes))
+			}
+			if tt.options != nil && tt.options.EmbedImages && images != len(tt.data.Shapes) {
+				t.Errorf("SvgSerialize() embedded %d images, want %d", images, len(tt.data.Shapes))
+			}
+			for _, shape := range tt.data.Shapes {
+				if !layers[shape.ColorName] {
+					t.Errorf("SvgSerialize() has no layer for %q", shape.ColorName)
+				}
+			}
+		})
+	}
+}
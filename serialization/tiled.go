@@ -0,0 +1,229 @@
+package serialization
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+type TiledFormat int
+
+const (
+	// Tiled's JSON map format.
+	TILED_TMJ TiledFormat = iota
+	// Tiled's XML map format.
+	TILED_TMX
+)
+
+const TILED_MAP_VERSION = "1.10"
+
+type TiledOptions struct {
+	Format TiledFormat
+	// Size of the source image in pixels. If either is 0, the size from the data's metadata is used,
+	// or if it has none, the map is sized to fit the shapes instead.
+	Width, Height int
+	// Size of the map's tiles in pixels. Defaults to 32x32.
+	TileWidth, TileHeight int
+}
+
+var DefaultTiledOptions = TiledOptions{
+	Format:     TILED_TMJ,
+	TileWidth:  32,
+	TileHeight: 32,
+}
+
+type TiledMap struct {
+	XMLName      xml.Name           `json:"-" xml:"map"`
+	Type         string             `json:"type" xml:"-"`
+	Version      string             `json:"version" xml:"version,attr"`
+	Orientation  string             `json:"orientation" xml:"orientation,attr"`
+	RenderOrder  string             `json:"renderorder" xml:"renderorder,attr"`
+	Width        int                `json:"width" xml:"width,attr"`
+	Height       int                `json:"height" xml:"height,attr"`
+	TileWidth    int                `json:"tilewidth" xml:"tilewidth,attr"`
+	TileHeight   int                `json:"tileheight" xml:"tileheight,attr"`
+	Infinite     bool               `json:"infinite" xml:"-"`
+	InfiniteAttr int                `json:"-" xml:"infinite,attr"`
+	NextLayerID  int                `json:"nextlayerid" xml:"nextlayerid,attr"`
+	NextObjectID int                `json:"nextobjectid" xml:"nextobjectid,attr"`
+	Layers       []TiledObjectGroup `json:"layers" xml:"objectgroup"`
+	Tilesets     []struct{}         `json:"tilesets" xml:"-"`
+}
+
+type TiledObjectGroup struct {
+	Type      string        `json:"type" xml:"-"`
+	ID        int           `json:"id" xml:"id,attr"`
+	Name      string        `json:"name" xml:"name,attr"`
+	DrawOrder string        `json:"draworder" xml:"-"`
+	Opacity   float64       `json:"opacity" xml:"-"`
+	Visible   bool          `json:"visible" xml:"-"`
+	X         int           `json:"x" xml:"-"`
+	Y         int           `json:"y" xml:"-"`
+	Objects   []TiledObject `json:"objects" xml:"object"`
+}
+
+type TiledObject struct {
+	ID          int              `json:"id" xml:"id,attr"`
+	Name        string           `json:"name" xml:"name,attr"`
+	Type        string           `json:"type" xml:"-"`
+	X           int              `json:"x" xml:"x,attr"`
+	Y           int              `json:"y" xml:"y,attr"`
+	Width       int              `json:"width" xml:"-"`
+	Height      int              `json:"height" xml:"-"`
+	Rotation    float64          `json:"rotation" xml:"-"`
+	Visible     bool             `json:"visible" xml:"-"`
+	Properties  []TiledProperty  `json:"properties" xml:"properties>property"`
+	Polygon     []TiledPoint     `json:"polygon,omitempty" xml:"-"`
+	Polyline    []TiledPoint     `json:"polyline,omitempty" xml:"-"`
+	PolygonXML  *TiledPointsAttr `json:"-" xml:"polygon"`
+	PolylineXML *TiledPointsAttr `json:"-" xml:"polyline"`
+}
+
+type TiledProperty struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Type  string `json:"type" xml:"type,attr"`
+	Value any    `json:"value" xml:"value,attr"`
+}
+
+type TiledPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type TiledPointsAttr struct {
+	Points string `xml:"points,attr"`
+}
+
+// Writes the shapes as a Tiled map, with one object layer for each color. Each shape is a polygon object
+// (or a polyline, if it's open) carrying its number and color as custom properties. Tiled polygons can't have
+// holes, so each hole is added after its shape as its own polygon object, with a "hole" property.
+func TiledSerialize(w io.Writer, data *main.BoardshapesData, options *TiledOptions) error {
+	if options == nil {
+		options = &DefaultTiledOptions
+	}
+	tileWidth, tileHeight := options.TileWidth, options.TileHeight
+	if tileWidth <= 0 {
+		tileWidth = DefaultTiledOptions.TileWidth
+	}
+	if tileHeight <= 0 {
+		tileHeight = DefaultTiledOptions.TileHeight
+	}
+
+	width, height := options.Width, options.Height
+	if (width == 0 || height == 0) && data.Metadata != nil {
+		width, height = data.Metadata.ImageWidth, data.Metadata.ImageHeight
+	}
+	if width == 0 || height == 0 {
+		width, height = 0, 0
+		for _, shape := range data.Shapes {
+			bounds := shape.Bounds()
+			width, height = max(width, bounds.Max.X), max(height, bounds.Max.Y)
+		}
+	}
+
+	tiledMap := TiledMap{
+		Type:        "map",
+		Version:     TILED_MAP_VERSION,
+		Orientation: "orthogonal",
+		RenderOrder: "right-down",
+		Width:       (width + tileWidth - 1) / tileWidth,
+		Height:      (height + tileHeight - 1) / tileHeight,
+		TileWidth:   tileWidth,
+		TileHeight:  tileHeight,
+		Layers:      make([]TiledObjectGroup, 0),
+		Tilesets:    make([]struct{}, 0),
+	}
+
+	// group the shapes by color, in the order the colors first appear
+	layerIndices := make(map[string]int)
+	nextObjectID := 1
+	for _, shape := range data.Shapes {
+		name := shape.ColorName
+		if name == "" {
+			name = svgColor(main.GetNRGBA(shape.Color))
+		}
+		i, ok := layerIndices[name]
+		if !ok {
+			i = len(tiledMap.Layers)
+			layerIndices[name] = i
+			tiledMap.Layers = append(tiledMap.Layers, TiledObjectGroup{
+				Type:      "objectgroup",
+				ID:        i + 1,
+				Name:      name,
+				DrawOrder: "topdown",
+				Opacity:   1,
+				Visible:   true,
+				Objects:   make([]TiledObject, 0),
+			})
+		}
+
+		properties := []TiledProperty{
+			{Name: "number", Type: "int", Value: shape.Number},
+			{Name: "color", Type: "color", Value: tiledColor(main.GetNRGBA(shape.Color))},
+		}
+		object := newTiledObject(nextObjectID, fmt.Sprintf("shape-%d", shape.Number), shape, shape.Path, shape.Open)
+		object.Properties = properties
+		tiledMap.Layers[i].Objects = append(tiledMap.Layers[i].Objects, object)
+		nextObjectID++
+
+		for j, hole := range shape.Holes {
+			object := newTiledObject(nextObjectID, fmt.Sprintf("shape-%d-hole-%d", shape.Number, j), shape, hole, false)
+			object.Properties = append(properties, TiledProperty{Name: "hole", Type: "bool", Value: true})
+			tiledMap.Layers[i].Objects = append(tiledMap.Layers[i].Objects, object)
+			nextObjectID++
+		}
+	}
+	tiledMap.NextLayerID = len(tiledMap.Layers) + 1
+	tiledMap.NextObjectID = nextObjectID
+
+	switch options.Format {
+	case TILED_TMX:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", " ")
+		if err := encoder.Encode(tiledMap); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	default:
+		return json.NewEncoder(w).Encode(tiledMap)
+	}
+}
+
+// Object points are relative to the object's position, so the shape's corner is used for it.
+func newTiledObject(id int, name string, shape main.ShapeData, path main.Path, open bool) TiledObject {
+	object := TiledObject{
+		ID:      id,
+		Name:    name,
+		X:       shape.CornerX,
+		Y:       shape.CornerY,
+		Visible: true,
+	}
+
+	points := make([]TiledPoint, len(path))
+	pointsAttr := make([]string, len(path))
+	for i, v := range path {
+		points[i] = TiledPoint{X: int(v.X), Y: int(v.Y)}
+		pointsAttr[i] = fmt.Sprintf("%d,%d", v.X, v.Y)
+	}
+	pointsXML := &TiledPointsAttr{Points: strings.Join(pointsAttr, " ")}
+	if open {
+		object.Polyline, object.PolylineXML = points, pointsXML
+	} else {
+		object.Polygon, object.PolygonXML = points, pointsXML
+	}
+	return object
+}
+
+// Tiled writes colors as #AARRGGBB.
+func tiledColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.A, c.R, c.G, c.B)
+}
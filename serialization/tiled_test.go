@@ -0,0 +1,87 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	main "github.com/boardshapes/boardshapes"
+)
+
+func TestTiledSerialize(t *testing.T) {
+	data := main.CreateShapes(loadImage("../test_images/lub.png"), main.ShapeCreationOptions{})
+	holes := 0
+	for _, shape := range data.Shapes {
+		holes += len(shape.Holes)
+	}
+
+	tests := []struct {
+		name    string
+		options *TiledOptions
+	}{
+		{
+			name: "tmj",
+		},
+		{
+			name:    "tmx",
+			options: &TiledOptions{Format: TILED_TMX, Width: 1000, Height: 500, TileWidth: 16, TileHeight: 16},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if err := TiledSerialize(w, data, tt.options); err != nil {
+				t.Fatalf("TiledSerialize() error = %v", err)
+			}
+
+			var tiledMap TiledMap
+			var err error
+			if tt.options != nil && tt.options.Format == TILED_TMX {
+				// property values can't be read back into an interface from XML
+				err = xml.Unmarshal(bytes.ReplaceAll(w.Bytes(), []byte(" value="), []byte(" v=")), &tiledMap)
+			} else {
+				err = json.Unmarshal(w.Bytes(), &tiledMap)
+			}
+			if err != nil {
+				t.Fatalf("TiledSerialize() wrote an invalid map: %v", err)
+			}
+
+			if tt.options != nil && tt.options.Width != 0 {
+				if tiledMap.Width != (tt.options.Width+tt.options.TileWidth-1)/tt.options.TileWidth ||
+					tiledMap.Height != (tt.options.Height+tt.options.TileHeight-1)/tt.options.TileHeight {
+					t.Errorf("TiledSerialize() map is %dx%d tiles, not sized to the image", tiledMap.Width, tiledMap.Height)
+				}
+			}
+
+			// every shape and hole should be an object, inside a layer for its color
+			objects := 0
+			ids := make(map[int]bool)
+			for _, layer := range tiledMap.Layers {
+				for _, object := range layer.Objects {
+					objects++
+					ids[object.ID] = true
+					if len(object.Properties) < 2 || object.Properties[0].Name != "number" || object.Properties[1].Name != "color" {
+						t.Errorf("TiledSerialize() object %q is missing its properties", object.Name)
+					}
+				}
+			}
+			if objects != len(data.Shapes)+holes {
+				t.Errorf("TiledSerialize() wrote %d objects, want %d", objects, len(data.Shapes)+holes)
+			}
+			if len(ids) != objects || tiledMap.NextObjectID != objects+1 {
+				t.Errorf("TiledSerialize() object ids aren't unique and sequential")
+			}
+
+			layers := make(map[string]bool)
+			for _, layer := range tiledMap.Layers {
+				layers[layer.Name] = true
+			}
+			for _, shape := range data.Shapes {
+				if !layers[shape.ColorName] {
+					t.Errorf("TiledSerialize() has no layer for %q", shape.ColorName)
+				}
+			}
+		})
+	}
+}
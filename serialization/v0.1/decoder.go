@@ -0,0 +1,517 @@
+package v0_1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	main "github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization/shared"
+)
+
+// Where chunks are read from.
+type ChunkSource interface {
+	io.Reader
+	io.ByteReader
+	ReadBytes(delim byte) ([]byte, error)
+}
+
+// Reads the rest of a chunk after its ID.
+type ChunkDataReader func(r ChunkSource, id byte) ([]byte, error)
+
+// Decodes the chunks that a later version of the format adds to, or changes from, version 0.1.
+type Extension struct {
+	// Reads the rest of a chunk after its ID, which is how the decoder finds where chunks end.
+	ReadChunkData ChunkDataReader
+	// Applies a chunk to the decoder. Returns false for chunks that are left to version 0.1.
+	ApplyChunk func(d *Decoder, chunk shared.Chunk) (bool, error)
+	// Applies a shape chunk to its shape. Returns false for chunks that are left to version 0.1.
+	ApplyShapeChunk func(shape *main.ShapeData, chunk shared.Chunk) (bool, error)
+}
+
+// Reads binary data chunk by chunk, or shape by shape, without reading all of it into memory first.
+type Decoder struct {
+	r   *checksumReader
+	ext Extension
+	// the checksum of everything before the last chunk read
+	sum       uint32
+	baseImage image.Image
+	// if set, reaching the end of the data without finding a checksum chunk is an error
+	requireChecksum bool
+	checked         bool
+	// if greater than 0, the most pixels a shape's image can have
+	maxImagePixels int
+
+	version   string
+	palette   main.Palette
+	colors    map[color.NRGBA]string
+	metadata  *main.Metadata
+	transform *main.Homography
+
+	// the first chunk of the next shape, read while looking for the end of the last one
+	pending *shared.Chunk
+}
+
+// Options are the same as for [BinaryDeserialize], and also include "requireChecksum" (bool)
+// and "maxImagePixels" (int).
+func NewDecoder(r io.Reader, options map[string]any) *Decoder {
+	return NewDecoderWithExtension(r, options, Extension{ReadChunkData: readChunkData})
+}
+
+// Returns a decoder for a later version of the format. Chunks that the extension reads but neither it nor
+// this version knows are passed through by NextChunk, and ignored when reading shapes.
+func NewDecoderWithExtension(r io.Reader, options map[string]any, ext Extension) *Decoder {
+	d := &Decoder{
+		r:              &checksumReader{r: bufio.NewReader(r), hash: crc32.NewIEEE()},
+		ext:            ext,
+		colors:         make(map[color.NRGBA]string),
+		maxImagePixels: shared.DEFAULT_MAX_IMAGE_PIXELS,
+	}
+	if img, ok := options["baseImage"].(image.Image); ok {
+		d.baseImage = img
+	}
+	d.requireChecksum, _ = options["requireChecksum"].(bool)
+	if maxImagePixels, ok := options["maxImagePixels"].(int); ok {
+		d.maxImagePixels = maxImagePixels
+	}
+	return d
+}
+
+// The version of the data, once its version chunk has been read.
+func (d *Decoder) Version() string {
+	return d.version
+}
+
+// The colors of the data's color table chunks that have been read so far.
+func (d *Decoder) Palette() main.Palette {
+	return d.palette
+}
+
+// The data's metadata and transform, once its metadata chunk has been read.
+func (d *Decoder) Metadata() (*main.Metadata, *main.Homography) {
+	return d.metadata, d.transform
+}
+
+// Reads the next chunk, returning io.EOF once there are none left.
+// Chunks that aren't shape chunks, such as version and color table chunks, are also applied to the decoder,
+// so that later shapes get their color names.
+func (d *Decoder) NextChunk() (shared.Chunk, error) {
+	if d.pending != nil {
+		chunk := *d.pending
+		d.pending = nil
+		return chunk, nil
+	}
+
+	// the checksum covers everything before the checksum chunk
+	sum := d.r.hash.Sum32()
+	id, err := d.r.ReadByte()
+	if err == io.EOF && d.requireChecksum && !d.checked {
+		return shared.Chunk{}, shared.ErrChecksumMissing
+	}
+	if err != nil {
+		return shared.Chunk{}, err
+	}
+	data, err := d.ext.ReadChunkData(d.r, id)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return shared.Chunk{}, err
+	}
+	chunk := shared.Chunk{ID: id, Data: data}
+	d.sum = sum
+
+	if d.ext.ApplyChunk != nil {
+		if handled, err := d.ext.ApplyChunk(d, chunk); handled {
+			if err != nil {
+				return shared.Chunk{}, err
+			}
+			return chunk, nil
+		}
+	}
+	switch id {
+	case CHUNK_VERSION:
+		d.version = shared.TrimNullByte(string(data))
+	case CHUNK_COLOR_TABLE:
+		entries, err := ReadColorTableEntries(bytes.NewBuffer(data))
+		if err != nil {
+			return shared.Chunk{}, err
+		}
+		d.AddColors(entries, len(entries), 0)
+	}
+	return chunk, nil
+}
+
+// Adds color table entries, the first paletteSize of which are also the palette's.
+// The tolerance replaces the palette's, unless it's 0.
+func (d *Decoder) AddColors(entries []main.PaletteEntry, paletteSize int, tolerance float64) {
+	for _, entry := range entries {
+		d.colors[entry.Color] = entry.Name
+	}
+	d.palette.Entries = append(d.palette.Entries, entries[:paletteSize]...)
+	if tolerance != 0 {
+		d.palette.Tolerance = tolerance
+	}
+}
+
+func (d *Decoder) SetMetadata(metadata *main.Metadata, transform *main.Homography) {
+	d.metadata, d.transform = metadata, transform
+}
+
+// Reads everything after the last chunk read through wrap, such as to decompress it.
+// The checksum is of the data that comes out of wrap.
+func (d *Decoder) Wrap(wrap func(r io.Reader) io.Reader) {
+	d.r = &checksumReader{r: bufio.NewReader(wrap(d.r.r)), hash: d.r.hash}
+}
+
+// Checks the checksum of everything before the last chunk read.
+func (d *Decoder) VerifyChecksum(sum uint32) error {
+	if sum != d.sum {
+		return shared.ErrChecksumMismatch
+	}
+	d.checked = true
+	return nil
+}
+
+// Reads the chunks of the next shape, returning io.EOF once there are none left.
+// A shape's chunks must all come before the next shape's, which is how [BinaryDeserialize] expects them too.
+func (d *Decoder) NextShape() (main.ShapeData, error) {
+	var shape main.ShapeData
+	usesMask := false
+	found := false
+	for {
+		chunk, err := d.NextChunk()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return main.ShapeData{}, err
+		}
+
+		number, ok := chunk.ShapeNumber()
+		if !ok {
+			continue
+		}
+		if found && number != shape.Number {
+			d.pending = &chunk
+			break
+		}
+		if !found {
+			shape.Number = number
+			found = true
+		}
+		if err := d.applyShapeChunk(&shape, chunk); err != nil {
+			return main.ShapeData{}, err
+		}
+		usesMask = usesMask || chunk.ID == CHUNK_SHAPE_MASK
+	}
+
+	if !found {
+		return main.ShapeData{}, io.EOF
+	}
+	d.finishShape(&shape, usesMask)
+	return shape, nil
+}
+
+// Adds the shape's color name, and restores its color if its image came from a mask.
+func (d *Decoder) finishShape(shape *main.ShapeData, usesMask bool) {
+	if shape.Color != nil {
+		if colorName, ok := d.colors[main.GetNRGBA(shape.Color)]; ok {
+			shape.ColorName = colorName
+		}
+	}
+	if !usesMask {
+		return
+	}
+
+	getPixelColor := func(_, _ int) color.Color {
+		return shape.Color
+	}
+	if d.baseImage != nil {
+		getPixelColor = func(x, y int) color.Color {
+			return d.baseImage.At(shape.CornerX+x, shape.CornerY+y)
+		}
+	}
+
+	img, ok := shape.Image.(main.SettableImage)
+	if ok {
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+					img.Set(x, y, getPixelColor(x, y))
+				}
+			}
+		}
+	}
+}
+
+// Reads the rest of a chunk after its ID. Chunks in this version don't say how long they are,
+// so the reader has to follow the structure of each type of chunk to find where it ends.
+func readChunkData(r ChunkSource, id byte) ([]byte, error) {
+	var buf bytes.Buffer
+	readN := func(n int64) error {
+		_, err := io.CopyN(&buf, r, n)
+		return err
+	}
+	readUint32 := func() (uint32, error) {
+		if err := readN(4); err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint32(buf.Bytes()[buf.Len()-4:]), nil
+	}
+	readUntilNull := func() error {
+		b, err := r.ReadBytes(0)
+		buf.Write(b)
+		return err
+	}
+
+	switch id {
+	case CHUNK_VERSION:
+		err := readUntilNull()
+		return buf.Bytes(), err
+	case CHUNK_COLOR_TABLE:
+		nColors, err := readUint32()
+		if err != nil {
+			return nil, err
+		}
+		for range nColors {
+			if err := readN(4); err != nil {
+				return nil, err
+			}
+			if err := readUntilNull(); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	case CHUNK_SHAPE_GEOMETRY, CHUNK_SHAPE_COLOR, CHUNK_SHAPE_IMAGE, CHUNK_SHAPE_MASK:
+		// every shape chunk starts with the shape's number
+		if err := readN(4); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, shared.ErrUnknownChunkType(id)
+	}
+
+	var err error
+	switch id {
+	case CHUNK_SHAPE_GEOMETRY:
+		if err = readN(4); err != nil {
+			return nil, err
+		}
+		var nVertices uint32
+		if nVertices, err = readUint32(); err == nil {
+			err = readN(4 * int64(nVertices))
+		}
+	case CHUNK_SHAPE_COLOR:
+		err = readN(4)
+	case CHUNK_SHAPE_IMAGE:
+		var l uint32
+		if l, err = readUint32(); err == nil {
+			err = readN(int64(l))
+		}
+	case CHUNK_SHAPE_MASK:
+		if err = readN(2 + 1); err == nil {
+			err = readUntilNull()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Reads the number of entries, then each entry's color and null-terminated name.
+func ReadColorTableEntries(buf *bytes.Buffer) ([]main.PaletteEntry, error) {
+	var nColors uint32
+	if err := binary.Read(buf, binary.BigEndian, &nColors); err != nil {
+		return nil, err
+	}
+	// every entry takes at least its color and the null at the end of its name
+	if uint64(nColors)*5 > uint64(buf.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	entries := make([]main.PaletteEntry, 0, nColors)
+	for range nColors {
+		channels := make([]byte, 4)
+		if _, err := io.ReadFull(buf, channels); err != nil {
+			return nil, err
+		}
+		colorName, err := buf.ReadString(0)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, main.PaletteEntry{
+			Name:  shared.TrimNullByte(colorName),
+			Color: color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: channels[3]},
+		})
+	}
+	return entries, nil
+}
+
+// Applies a shape chunk to the shape. Masks are read as black images, which [Decoder.finishShape] colors in.
+func (d *Decoder) applyShapeChunk(shape *main.ShapeData, chunk shared.Chunk) error {
+	// running out of data in the middle of a chunk isn't the end of the data
+	if err := d.readShapeChunk(shape, chunk); err != io.EOF {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+func (d *Decoder) readShapeChunk(shape *main.ShapeData, chunk shared.Chunk) error {
+	if d.ext.ApplyShapeChunk != nil {
+		if handled, err := d.ext.ApplyShapeChunk(shape, chunk); handled {
+			return err
+		}
+	}
+	buf := bytes.NewBuffer(chunk.Data[4:])
+
+	switch chunk.ID {
+	case CHUNK_SHAPE_GEOMETRY:
+		d := make([]byte, 8)
+		if _, err := io.ReadFull(buf, d); err != nil {
+			return err
+		}
+
+		cornerX, cornerY := binary.BigEndian.Uint16(d[0:2]), binary.BigEndian.Uint16(d[2:4])
+		shape.CornerX = int(cornerX)
+		shape.CornerY = int(cornerY)
+
+		path, err := ReadVertices(buf, binary.BigEndian.Uint32(d[4:8]))
+		if err != nil {
+			return err
+		}
+		shape.Path = path
+	case CHUNK_SHAPE_COLOR:
+		d := make([]byte, 4)
+		if _, err := io.ReadFull(buf, d); err != nil {
+			return err
+		}
+		shape.Color = color.NRGBA{R: d[0], G: d[1], B: d[2], A: d[3]}
+	case CHUNK_SHAPE_IMAGE:
+		var l uint32
+		if err := binary.Read(buf, binary.BigEndian, &l); err != nil {
+			return err
+		}
+		if uint64(l) > uint64(buf.Len()) {
+			return io.ErrUnexpectedEOF
+		}
+		img, err := decodeImage(buf.Next(int(l)), d.maxImagePixels)
+		if err != nil {
+			return err
+		}
+		shape.Image = img
+	case CHUNK_SHAPE_MASK:
+		var width uint16
+		if err := binary.Read(buf, binary.BigEndian, &width); err != nil {
+			return err
+		}
+		startsFilled, err := buf.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		filled := startsFilled > 0
+		b, err := buf.ReadBytes(0x00)
+		if err != nil {
+			return err
+		}
+
+		runLengths := make([]uint, 0)
+		for len(b) > 0 {
+			runLength, nBytes := binary.Uvarint(b)
+			if nBytes <= 0 {
+				return errors.New("deserialization: mask has an invalid run-length")
+			}
+			runLengths = append(runLengths, uint(runLength))
+			b = b[nBytes:]
+		}
+
+		sum := uint(0)
+		for _, rl := range runLengths {
+			sum += rl
+			if d.maxImagePixels > 0 && (rl > uint(d.maxImagePixels) || sum > uint(d.maxImagePixels)) {
+				return shared.ErrImageTooLarge
+			}
+		}
+
+		if width == 0 || sum%uint(width) != 0 {
+			return errors.New("deserialization: mask width does not divide evenly into total number of pixels in mask")
+		}
+
+		height := sum / uint(width)
+		img := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+		i := 0
+		for _, rl := range runLengths {
+			for range rl {
+				if filled {
+					img.Set(i%int(width), i/int(width), main.Black)
+				} else {
+					img.Set(i%int(width), i/int(width), main.Blank)
+				}
+				i++
+			}
+			filled = !filled
+		}
+		shape.Image = img
+	}
+
+	return nil
+}
+
+// Decodes a shape's PNG image, checking its size before decoding it, since decoding allocates all of its pixels.
+func decodeImage(data []byte, maxPixels int) (image.Image, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if maxPixels > 0 && int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return nil, shared.ErrImageTooLarge
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// Reads each vertex as its x and y.
+func ReadVertices(buf *bytes.Buffer, nVertices uint32) ([]main.Vertex, error) {
+	if uint64(nVertices)*4 > uint64(buf.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	d := buf.Next(4 * int(nVertices))
+	path := make([]main.Vertex, nVertices)
+	for i := range path {
+		path[i] = main.Vertex{X: binary.BigEndian.Uint16(d[i*4:]), Y: binary.BigEndian.Uint16(d[i*4+2:])}
+	}
+	return path, nil
+}
+
+// Hashes everything that's read through it, for checking the data's checksum.
+type checksumReader struct {
+	r    *bufio.Reader
+	hash hash.Hash32
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.hash.Write(p[:n])
+	return n, err
+}
+
+func (cr *checksumReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.hash.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (cr *checksumReader) ReadBytes(delim byte) ([]byte, error) {
+	b, err := cr.r.ReadBytes(delim)
+	cr.hash.Write(b)
+	return b, err
+}
@@ -1,14 +1,9 @@
 package v0_1
 
 import (
-	"bytes"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
-	"errors"
 	"image"
-	"image/color"
-	"image/png"
 	"io"
 
 	main "github.com/boardshapes/boardshapes"
@@ -25,237 +20,115 @@ const (
 )
 
 func BinaryDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData, error) {
-	data := &main.BoardshapesData{}
-	var baseImage image.Image
-	if img, ok := options["baseImage"].(image.Image); ok {
-		baseImage = img
-	}
+	return DeserializeFrom(NewDecoder(r, options))
+}
 
-	buf := bytes.Buffer{}
-	_, err := buf.ReadFrom(r)
-	if err != nil {
-		return nil, err
-	}
+// Reads all the data left in the decoder.
+func DeserializeFrom(decoder *Decoder) (*main.BoardshapesData, error) {
+	data := &main.BoardshapesData{}
 
-	colors := make(map[color.NRGBA]string, 0)
-	shapes := make(map[int]main.ShapeData, 0)
-	shapesUsingMasks := make([]int, 0)
+	// a shape's chunks don't have to be next to each other here, so they're gathered before finishing the shapes
+	shapes := make(map[int]*main.ShapeData, 0)
+	shapeNumbers := make([]int, 0)
+	shapesUsingMasks := make(map[int]bool, 0)
 	for {
-		chunkId, err := buf.ReadByte()
+		chunk, err := decoder.NextChunk()
 		if err == io.EOF {
 			break
 		} else if err != nil {
 			return nil, err
 		}
 
-		switch chunkId {
-		case CHUNK_VERSION:
-			version, err := buf.ReadString(0)
-			if err != nil {
-				return nil, err
-			}
-			version = shared.TrimNullByte(version)
-			data.Version = version
-		case CHUNK_COLOR_TABLE:
-			nColors := new(uint32)
-			binary.Read(&buf, binary.BigEndian, nColors)
-			for range *nColors {
-				channels := make([]byte, 4)
-				_, err := buf.Read(channels)
-				if err != nil {
-					return nil, err
-				}
-				r, g, b, a := channels[0], channels[1], channels[2], channels[3]
-				colorName, err := buf.ReadString(0)
-				if err != nil {
-					return nil, err
-				}
-				colorName = shared.TrimNullByte(colorName)
-				colors[color.NRGBA{R: r, G: g, B: b, A: a}] = colorName
-			}
-		case CHUNK_SHAPE_GEOMETRY, CHUNK_SHAPE_COLOR, CHUNK_SHAPE_IMAGE, CHUNK_SHAPE_MASK: // shape chunks
-			var shape main.ShapeData
-			var inShapesMap bool
-			shapeNumber := new(uint32)
-			binary.Read(&buf, binary.BigEndian, shapeNumber)
-
-			if shape, inShapesMap = shapes[int(*shapeNumber)]; !inShapesMap {
-				shape = main.ShapeData{
-					Number: int(*shapeNumber),
-				}
-			}
-
-			switch chunkId {
-			case CHUNK_SHAPE_GEOMETRY:
-				d := make([]byte, 8)
-				_, err := buf.Read(d)
-				if err != nil {
-					return nil, err
-				}
-
-				cornerX, cornerY, nVertices := binary.BigEndian.Uint16(d[0:2]), binary.BigEndian.Uint16(d[2:4]), binary.BigEndian.Uint32(d[4:8])
-				shape.CornerX = int(cornerX)
-				shape.CornerY = int(cornerY)
-
-				path := make([]main.Vertex, nVertices)
-				for i := range nVertices {
-					bv := make([]byte, 4)
-					_, err := buf.Read(bv)
-					if err != nil {
-						return nil, err
-					}
-					x, y := binary.BigEndian.Uint16(bv[0:2]), binary.BigEndian.Uint16(bv[2:4])
-					path[i] = main.Vertex{X: uint16(x), Y: uint16(y)}
-				}
-
-				shape.Path = path
-			case CHUNK_SHAPE_COLOR:
-				d := make([]byte, 4)
-				_, err := buf.Read(d)
-				if err != nil {
-					return nil, err
-				}
-				r, g, b, a := d[0], d[1], d[2], d[3]
-				shape.Color = color.NRGBA{R: r, G: g, B: b, A: a}
-			case CHUNK_SHAPE_IMAGE:
-				l := new(uint32)
-				binary.Read(&buf, binary.BigEndian, l)
-				var pngBuf bytes.Buffer
-				pngBuf.Grow(int(*l))
-				_, err = io.CopyN(&pngBuf, &buf, int64(*l))
-				if err != nil {
-					return nil, err
-				}
-				img, err := png.Decode(&pngBuf)
-				if err != nil {
-					return nil, err
-				}
-				shape.Image = img
-			case CHUNK_SHAPE_MASK:
-				shapesUsingMasks = append(shapesUsingMasks, shape.Number)
-				width := new(uint16)
-				binary.Read(&buf, binary.BigEndian, width)
-				startsFilled, err := buf.ReadByte()
-				if err != nil {
-					return nil, err
-				}
-
-				filled := startsFilled > 0
-				b, err := buf.ReadBytes(0x00)
-				if err != nil {
-					return nil, err
-				}
-
-				runLengths := make([]uint, 0)
-				for len(b) > 0 {
-					runLength, nBytes := binary.Uvarint(b)
-					runLengths = append(runLengths, uint(runLength))
-					b = b[nBytes:]
-				}
-
-				sum := uint(0)
-				for _, rl := range runLengths {
-					sum += rl
-				}
-
-				if sum%uint(*width) != 0 {
-					return nil, errors.New("deserialization: mask width does not divide evenly into total number of pixels in mask")
-				}
-
-				height := sum / uint(*width)
-				img := image.NewNRGBA(image.Rect(0, 0, int(*width), int(height)))
-				i := 0
-				for _, rl := range runLengths {
-					for range rl {
-						if filled {
-							img.Set(i%int(*width), i/int(*width), main.Black)
-						} else {
-							img.Set(i%int(*width), i/int(*width), main.Blank)
-						}
-						i++
-					}
-					filled = !filled
-				}
-				shape.Image = img
-			}
-
-			shapes[int(*shapeNumber)] = shape
-		default:
-			return nil, shared.ErrUnknownChunkType(chunkId)
+		shapeNumber, ok := chunk.ShapeNumber()
+		if !ok {
+			continue
 		}
-	}
-
-	// add color names to shapes
-	for i, shape := range shapes {
-		if shape.Color != nil {
-			colorName, ok := colors[main.GetNRGBA(shape.Color)]
-			if ok {
-				shape.ColorName = colorName
-				shapes[i] = shape
-			}
+		shape, inShapesMap := shapes[shapeNumber]
+		if !inShapesMap {
+			shape = &main.ShapeData{Number: shapeNumber}
+			shapes[shapeNumber] = shape
+			shapeNumbers = append(shapeNumbers, shapeNumber)
 		}
-	}
-
-	var getPixelColor func(x, y int, shape main.ShapeData) color.Color
-	if baseImage != nil {
-		getPixelColor = func(x, y int, shape main.ShapeData) color.Color {
-			return baseImage.At(shape.CornerX+x, shape.CornerY+y)
+		if err := decoder.applyShapeChunk(shape, chunk); err != nil {
+			return nil, err
 		}
-	} else {
-		getPixelColor = func(_, _ int, shape main.ShapeData) color.Color {
-			return shape.Color
-		}
-	}
-
-	// restore color to shapes using masks
-	for _, shapeNumber := range shapesUsingMasks {
-		shape := shapes[shapeNumber]
-		img, ok := shape.Image.(main.SettableImage)
-		if ok {
-			for y := 0; y < img.Bounds().Dy(); y++ {
-				for x := 0; x < img.Bounds().Dx(); x++ {
-					if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
-						img.Set(x, y, getPixelColor(x, y, shape))
-					}
-				}
-			}
+		if chunk.ID == CHUNK_SHAPE_MASK {
+			shapesUsingMasks[shapeNumber] = true
 		}
 	}
 
+	data.Version = decoder.Version()
+	data.Palette = decoder.Palette()
+	data.Metadata, data.Transform = decoder.Metadata()
 	data.Shapes = make([]main.ShapeData, 0, len(shapes))
-	for _, shape := range shapes {
-		data.Shapes = append(data.Shapes, shape)
+	for _, shapeNumber := range shapeNumbers {
+		shape := shapes[shapeNumber]
+		decoder.finishShape(shape, shapesUsingMasks[shapeNumber])
+		data.Shapes = append(data.Shapes, *shape)
 	}
 
 	return data, nil
 }
 
-type JSONData struct {
-	Version string          `json:"version"`
-	Shapes  []JSONShapeData `json:"shapes"`
-}
-
-type JSONShapeData struct {
-	Number      int         `json:"number"`
-	CornerX     int         `json:"cornerX"`
-	CornerY     int         `json:"cornerY"`
-	Shape       []uint16    `json:"path"`
-	Color       color.NRGBA `json:"color"`
-	ColorString string      `json:"colorString"`
-	Image       string      `json:"image"`
+// Unflattens curves from where the first one starts, then the control points and end of each one.
+func unflattenCurves(flat []float32) []main.CubicBezier {
+	if len(flat) < 8 {
+		return nil
+	}
+	curves := make([]main.CubicBezier, (len(flat)-2)/6)
+	start := main.CurvePoint{X: flat[0], Y: flat[1]}
+	for i := range curves {
+		f := flat[2+i*6:]
+		curves[i] = main.CubicBezier{
+			Start:    start,
+			Control1: main.CurvePoint{X: f[0], Y: f[1]},
+			Control2: main.CurvePoint{X: f[2], Y: f[3]},
+			End:      main.CurvePoint{X: f[4], Y: f[5]},
+		}
+		start = curves[i].End
+	}
+	return curves
 }
 
+// Options can include "maxImagePixels" (int), like for [BinaryDeserialize].
 func JsonDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData, error) {
-	var jsonData JSONData
+	maxImagePixels := shared.DEFAULT_MAX_IMAGE_PIXELS
+	if n, ok := options["maxImagePixels"].(int); ok {
+		maxImagePixels = n
+	}
+
+	var jsonData shared.JSONData
 	if err := json.NewDecoder(r).Decode(&jsonData); err != nil {
 		return nil, err
 	}
 
 	data := &main.BoardshapesData{
-		Version: jsonData.Version,
-		Shapes:  make([]main.ShapeData, len(jsonData.Shapes)),
+		Version:   jsonData.Version,
+		Transform: jsonData.Transform,
+		Shapes:    make([]main.ShapeData, len(jsonData.Shapes)),
+	}
+
+	if metadata := jsonData.Metadata; metadata != nil {
+		data.Metadata = &main.Metadata{
+			ImageWidth:   metadata.ImageWidth,
+			ImageHeight:  metadata.ImageHeight,
+			SourceWidth:  metadata.SourceWidth,
+			SourceHeight: metadata.SourceHeight,
+			Scale:        metadata.Scale,
+			Options:      metadata.Options,
+			CreatedAt:    metadata.CreatedAt,
+			Tags:         metadata.Tags,
+		}
 	}
 
+	for _, entry := range jsonData.Palette {
+		data.Palette.Entries = append(data.Palette.Entries, main.PaletteEntry{
+			Name:  entry.Name,
+			Color: entry.Color,
+		})
+	}
+	data.Palette.Tolerance = jsonData.PaletteTolerance
+
 	for i, jsonShape := range jsonData.Shapes {
 		path := make([]main.Vertex, len(jsonShape.Shape)/2)
 		for j := range path {
@@ -265,26 +138,73 @@ func JsonDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData
 			}
 		}
 
+		var holes []main.Path
+		if len(jsonShape.Holes) > 0 {
+			holes = make([]main.Path, len(jsonShape.Holes))
+			for j, jsonHole := range jsonShape.Holes {
+				holes[j] = make([]main.Vertex, len(jsonHole)/2)
+				for k := range holes[j] {
+					holes[j][k] = main.Vertex{
+						X: jsonHole[k*2],
+						Y: jsonHole[k*2+1],
+					}
+				}
+			}
+		}
+
+		var primitive *main.Primitive
+		if jsonShape.Primitive != nil {
+			kind, ok := main.ParsePrimitiveKind(jsonShape.Primitive.Kind)
+			if !ok {
+				kind = main.PRIMITIVE_FREEFORM
+			}
+			primitive = &main.Primitive{
+				Kind:       kind,
+				Confidence: jsonShape.Primitive.Confidence,
+				CenterX:    jsonShape.Primitive.CenterX,
+				CenterY:    jsonShape.Primitive.CenterY,
+				RadiusX:    jsonShape.Primitive.RadiusX,
+				RadiusY:    jsonShape.Primitive.RadiusY,
+				Angle:      jsonShape.Primitive.Angle,
+			}
+			for k := 0; k+1 < len(jsonShape.Primitive.Corners); k += 2 {
+				primitive.Corners = append(primitive.Corners, main.Vertex{
+					X: jsonShape.Primitive.Corners[k],
+					Y: jsonShape.Primitive.Corners[k+1],
+				})
+			}
+		}
+
+		var holeCurves [][]main.CubicBezier
+		for _, flat := range jsonShape.HoleCurves {
+			holeCurves = append(holeCurves, unflattenCurves(flat))
+		}
+
 		var img image.Image
 		if jsonShape.Image != "" {
 			imgBytes, err := base64.StdEncoding.DecodeString(jsonShape.Image)
 			if err != nil {
 				return nil, err
 			}
-			img, err = png.Decode(bytes.NewReader(imgBytes))
+			img, err = decodeImage(imgBytes, maxImagePixels)
 			if err != nil {
 				return nil, err
 			}
 		}
 
 		data.Shapes[i] = main.ShapeData{
-			Number:    jsonShape.Number,
-			CornerX:   jsonShape.CornerX,
-			CornerY:   jsonShape.CornerY,
-			Path:      path,
-			Color:     jsonShape.Color,
-			ColorName: jsonShape.ColorString,
-			Image:     img,
+			Number:     jsonShape.Number,
+			CornerX:    jsonShape.CornerX,
+			CornerY:    jsonShape.CornerY,
+			Path:       path,
+			Color:      jsonShape.Color,
+			ColorName:  jsonShape.ColorString,
+			Image:      img,
+			Holes:      holes,
+			Open:       jsonShape.Open,
+			Primitive:  primitive,
+			Curves:     unflattenCurves(jsonShape.Curves),
+			HoleCurves: holeCurves,
 		}
 	}
 
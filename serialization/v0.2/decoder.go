@@ -0,0 +1,290 @@
+package v0_2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	main "github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization/shared"
+	v0_1 "github.com/boardshapes/boardshapes/serialization/v0.1"
+)
+
+// Reads the entries of a color table, along with how many of them (from the start) are the palette's
+// and the palette's tolerance. Color tables without those are all palette entries, like in version 0.1.
+func readColorTable(data []byte) (entries []main.PaletteEntry, paletteSize int, tolerance float64, err error) {
+	buf := bytes.NewBuffer(data)
+	if entries, err = v0_1.ReadColorTableEntries(buf); err != nil {
+		return nil, 0, 0, err
+	}
+	if buf.Len() == 0 {
+		return entries, len(entries), 0, nil
+	}
+
+	var size uint32
+	var toleranceBits uint64
+	if err := binary.Read(buf, binary.BigEndian, &size); err != nil {
+		return nil, 0, 0, err
+	}
+	if err := binary.Read(buf, binary.BigEndian, &toleranceBits); err != nil {
+		return nil, 0, 0, err
+	}
+	if int(size) > len(entries) {
+		return nil, 0, 0, errors.New("deserialization: color table has more palette entries than colors")
+	}
+	return entries, int(size), math.Float64frombits(toleranceBits), nil
+}
+
+func readMetadata(data []byte) (*main.Metadata, *main.Homography, error) {
+	buf := bytes.NewBuffer(data)
+	flags, err := buf.ReadByte()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var metadata *main.Metadata
+	if flags&METADATA_FLAG_METADATA > 0 {
+		var fields struct {
+			ImageWidth, ImageHeight   uint32
+			SourceWidth, SourceHeight uint32
+			Scale                     float64
+			CreatedAt                 int64
+		}
+		if err := binary.Read(buf, binary.BigEndian, &fields); err != nil {
+			return nil, nil, err
+		}
+		metadata = &main.Metadata{
+			ImageWidth:   int(fields.ImageWidth),
+			ImageHeight:  int(fields.ImageHeight),
+			SourceWidth:  int(fields.SourceWidth),
+			SourceHeight: int(fields.SourceHeight),
+			Scale:        fields.Scale,
+		}
+		if fields.CreatedAt != 0 {
+			metadata.CreatedAt = time.Unix(0, fields.CreatedAt).UTC()
+		}
+		if metadata.Options, err = readStringMap(buf); err != nil {
+			return nil, nil, err
+		}
+		if metadata.Tags, err = readStringMap(buf); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var transform *main.Homography
+	if flags&METADATA_FLAG_TRANSFORM > 0 {
+		transform = new(main.Homography)
+		if err := binary.Read(buf, binary.BigEndian, transform); err != nil {
+			return nil, nil, err
+		}
+	}
+	return metadata, transform, nil
+}
+
+// Reads the number of entries, then each key and value as null-terminated strings.
+func readStringMap(buf *bytes.Buffer) (map[string]string, error) {
+	var n uint32
+	if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	m := make(map[string]string)
+	for range n {
+		key, err := buf.ReadString(0)
+		if err != nil {
+			return nil, err
+		}
+		value, err := buf.ReadString(0)
+		if err != nil {
+			return nil, err
+		}
+		m[shared.TrimNullByte(key)] = shared.TrimNullByte(value)
+	}
+	return m, nil
+}
+
+// Applies the shape chunks that are new in this version.
+func applyShapeChunk(shape *main.ShapeData, chunk shared.Chunk) (bool, error) {
+	buf := bytes.NewBuffer(chunk.Data[4:])
+
+	switch chunk.ID {
+	case CHUNK_SHAPE_DELTA_GEOMETRY:
+		d := make([]byte, 4)
+		if _, err := io.ReadFull(buf, d); err != nil {
+			return true, err
+		}
+		shape.CornerX = int(binary.BigEndian.Uint16(d[0:2]))
+		shape.CornerY = int(binary.BigEndian.Uint16(d[2:4]))
+
+		path, err := readDeltaVertices(buf)
+		if err != nil {
+			return true, err
+		}
+		shape.Path = path
+	case CHUNK_SHAPE_DELTA_HOLES:
+		nHoles, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return true, err
+		}
+		// every hole takes at least a byte
+		if nHoles > uint64(buf.Len()) {
+			return true, io.ErrUnexpectedEOF
+		}
+
+		holes := make([]main.Path, nHoles)
+		for i := range holes {
+			if holes[i], err = readDeltaVertices(buf); err != nil {
+				return true, err
+			}
+		}
+		shape.Holes = holes
+	case CHUNK_SHAPE_HOLES:
+		var nHoles uint32
+		if err := binary.Read(buf, binary.BigEndian, &nHoles); err != nil {
+			return true, err
+		}
+		// every hole takes at least its number of vertices
+		if uint64(nHoles)*4 > uint64(buf.Len()) {
+			return true, io.ErrUnexpectedEOF
+		}
+
+		holes := make([]main.Path, nHoles)
+		for i := range nHoles {
+			var nVertices uint32
+			if err := binary.Read(buf, binary.BigEndian, &nVertices); err != nil {
+				return true, err
+			}
+			hole, err := v0_1.ReadVertices(buf, nVertices)
+			if err != nil {
+				return true, err
+			}
+			holes[i] = hole
+		}
+
+		shape.Holes = holes
+	case CHUNK_SHAPE_FLAGS:
+		flags, err := buf.ReadByte()
+		if err != nil {
+			return true, err
+		}
+		shape.Open = flags&SHAPE_FLAG_OPEN > 0
+	case CHUNK_SHAPE_PRIMITIVE:
+		d := make([]byte, 1+6*8+4)
+		if _, err := io.ReadFull(buf, d); err != nil {
+			return true, err
+		}
+		f := func(i int) float64 {
+			return math.Float64frombits(binary.BigEndian.Uint64(d[1+i*8:]))
+		}
+		primitive := &main.Primitive{
+			Kind:       main.PrimitiveKind(d[0]),
+			Confidence: f(0),
+			CenterX:    f(1),
+			CenterY:    f(2),
+			RadiusX:    f(3),
+			RadiusY:    f(4),
+			Angle:      f(5),
+		}
+
+		nCorners := binary.BigEndian.Uint32(d[1+6*8:])
+		if nCorners > 0 {
+			corners, err := v0_1.ReadVertices(buf, nCorners)
+			if err != nil {
+				return true, err
+			}
+			primitive.Corners = corners
+		}
+
+		shape.Primitive = primitive
+	case CHUNK_SHAPE_CURVES:
+		curves, err := readCurves(buf)
+		if err != nil {
+			return true, err
+		}
+		shape.Curves = curves
+
+		var nHoles uint32
+		if err := binary.Read(buf, binary.BigEndian, &nHoles); err != nil {
+			return true, err
+		}
+		// every hole takes at least its number of curves
+		if uint64(nHoles)*4 > uint64(buf.Len()) {
+			return true, io.ErrUnexpectedEOF
+		}
+		if nHoles > 0 {
+			shape.HoleCurves = make([][]main.CubicBezier, nHoles)
+		}
+		for i := range nHoles {
+			if shape.HoleCurves[i], err = readCurves(buf); err != nil {
+				return true, err
+			}
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// Reads the number of vertices as a varint, then each vertex as the difference from the one before it,
+// starting from (0, 0).
+func readDeltaVertices(buf *bytes.Buffer) ([]main.Vertex, error) {
+	nVertices, err := binary.ReadUvarint(buf)
+	if err != nil {
+		return nil, err
+	}
+	// every vertex takes at least 2 bytes
+	if nVertices > uint64(buf.Len()/2) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	path := make([]main.Vertex, nVertices)
+	x, y := int64(0), int64(0)
+	for i := range path {
+		dx, err := binary.ReadVarint(buf)
+		if err != nil {
+			return nil, err
+		}
+		dy, err := binary.ReadVarint(buf)
+		if err != nil {
+			return nil, err
+		}
+		x, y = x+dx, y+dy
+		if x < 0 || y < 0 || x > math.MaxUint16 || y > math.MaxUint16 {
+			return nil, errors.New("deserialization: delta geometry vertex is out of range")
+		}
+		path[i] = main.Vertex{X: uint16(x), Y: uint16(y)}
+	}
+	return path, nil
+}
+
+// Reads the number of curves, then where the first one starts,
+// then the control points and end of each one (which is where the next one starts).
+func readCurves(buf *bytes.Buffer) ([]main.CubicBezier, error) {
+	var nCurves uint32
+	if err := binary.Read(buf, binary.BigEndian, &nCurves); err != nil {
+		return nil, err
+	}
+	if nCurves == 0 {
+		return []main.CubicBezier{}, nil
+	}
+	if 8*(1+3*uint64(nCurves)) > uint64(buf.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	curves := make([]main.CubicBezier, nCurves)
+	d := buf.Next(8 * (1 + 3*int(nCurves)))
+	point := func(i int) main.CurvePoint {
+		return main.CurvePoint{
+			X: math.Float32frombits(binary.BigEndian.Uint32(d[i*8:])),
+			Y: math.Float32frombits(binary.BigEndian.Uint32(d[i*8+4:])),
+		}
+	}
+	start := point(0)
+	for i := range curves {
+		curves[i] = main.CubicBezier{Start: start, Control1: point(i*3 + 1), Control2: point(i*3 + 2), End: point(i*3 + 3)}
+		start = curves[i].End
+	}
+	return curves, nil
+}
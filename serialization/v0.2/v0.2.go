@@ -0,0 +1,139 @@
+package v0_2
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+
+	main "github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization/shared"
+	v0_1 "github.com/boardshapes/boardshapes/serialization/v0.1"
+)
+
+// Chunks from version 0.1 keep their IDs.
+const (
+	CHUNK_VERSION              = v0_1.CHUNK_VERSION
+	CHUNK_CHECKSUM             = 1
+	CHUNK_COLOR_TABLE          = v0_1.CHUNK_COLOR_TABLE
+	CHUNK_METADATA             = 3
+	CHUNK_COMPRESSION          = 4
+	CHUNK_SHAPE_HOLES          = 12
+	CHUNK_SHAPE_FLAGS          = 13
+	CHUNK_SHAPE_PRIMITIVE      = 14
+	CHUNK_SHAPE_CURVES         = 15
+	CHUNK_SHAPE_DELTA_GEOMETRY = 16
+	CHUNK_SHAPE_DELTA_HOLES    = 17
+)
+
+const (
+	SHAPE_FLAG_OPEN = 0b00000001
+)
+
+const (
+	METADATA_FLAG_METADATA  = 0b00000001
+	METADATA_FLAG_TRANSFORM = 0b00000010
+)
+
+const (
+	COMPRESSION_NONE  = 0
+	COMPRESSION_FLATE = 1
+)
+
+// Chunks are the same as in version 0.1, except that every chunk but the version chunk has its length
+// after its ID, so chunks of unknown types can be skipped. Color tables can also end with the palette's size
+// and tolerance, and there are new chunks for checksums, metadata, compression and more of each shape.
+func BinaryDeserialize(r io.Reader, options map[string]any) (*main.BoardshapesData, error) {
+	return v0_1.DeserializeFrom(NewDecoder(r, options))
+}
+
+// Options are the same as for [BinaryDeserialize], and also include "maxDecompressedSize" (int64).
+func NewDecoder(r io.Reader, options map[string]any) *v0_1.Decoder {
+	maxDecompressedSize, _ := options["maxDecompressedSize"].(int64)
+	return v0_1.NewDecoderWithExtension(r, options, v0_1.Extension{
+		ReadChunkData: readChunkData,
+		ApplyChunk: func(d *v0_1.Decoder, chunk shared.Chunk) (bool, error) {
+			return applyChunk(d, chunk, maxDecompressedSize)
+		},
+		ApplyShapeChunk: applyShapeChunk,
+	})
+}
+
+// The JSON format didn't change in this version.
+var JsonDeserialize = v0_1.JsonDeserialize
+
+func readChunkData(r v0_1.ChunkSource, id byte) ([]byte, error) {
+	// the version chunk is the same in every version, so that it can always be found
+	if id == CHUNK_VERSION {
+		return r.ReadBytes(0)
+	}
+
+	d := make([]byte, 4)
+	if _, err := io.ReadFull(r, d); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(binary.BigEndian.Uint32(d))); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func applyChunk(d *v0_1.Decoder, chunk shared.Chunk, maxDecompressedSize int64) (bool, error) {
+	switch chunk.ID {
+	case CHUNK_COLOR_TABLE:
+		entries, paletteSize, tolerance, err := readColorTable(chunk.Data)
+		if err != nil {
+			return true, err
+		}
+		d.AddColors(entries, paletteSize, tolerance)
+	case CHUNK_METADATA:
+		metadata, transform, err := readMetadata(chunk.Data)
+		if err != nil {
+			return true, err
+		}
+		d.SetMetadata(metadata, transform)
+	case CHUNK_COMPRESSION:
+		// everything after this chunk is compressed, but the checksum is of the data before it was compressed
+		if len(chunk.Data) != 1 {
+			return true, shared.ErrUnknownCompression
+		}
+		switch chunk.Data[0] {
+		case COMPRESSION_NONE:
+		case COMPRESSION_FLATE:
+			d.Wrap(func(r io.Reader) io.Reader {
+				r = flate.NewReader(r)
+				if maxDecompressedSize > 0 {
+					r = &limitedReader{r: io.LimitReader(r, maxDecompressedSize+1), n: maxDecompressedSize}
+				}
+				return r
+			})
+		default:
+			return true, shared.ErrUnknownCompression
+		}
+	case CHUNK_CHECKSUM:
+		if len(chunk.Data) != 4 {
+			return true, shared.ErrChecksumMismatch
+		}
+		return true, d.VerifyChecksum(binary.BigEndian.Uint32(chunk.Data))
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// Fails with [shared.ErrDecompressedTooLarge] once more than n bytes have been read through it.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.n -= int64(n)
+	if lr.n < 0 {
+		return n + int(lr.n), shared.ErrDecompressedTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,329 @@
+// Serves shape generation, image simplification and reserialization over HTTP.
+//
+// Every endpoint takes a POST request whose body is the uploaded file, either as it is or as the "file"
+// field of a multipart form:
+//   - /generate takes an image and responds with shape data.
+//   - /simplify takes an image and responds with a PNG of it simplified.
+//   - /reserialize takes binary or JSON shape data and responds with it serialized again.
+//
+// Query parameters set the same options as the flags of the CLI tool:
+//   - resize: resizes the image to fit the size, in the format [width]x[height] with either one optional.
+//     If empty, the image is resized to fit 1920x1080.
+//   - epsilon: the epsilon for optimizing shapes, like [boardshapes.ShapeCreationOptions.EpsilonRDP].
+//   - binary: responds with binary data instead of JSON, if "true".
+//   - compress: compresses binary data, if "true".
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boardshapes/boardshapes"
+	"github.com/boardshapes/boardshapes/serialization"
+)
+
+var ErrInvalidResize = errors.New("server: invalid resize, use [width]x[height], e.g. 800x600, 800x, x600")
+
+type Options struct {
+	// The largest request body accepted, in bytes. Larger requests get a 413 response.
+	MaxRequestSize int64
+	// The most pixels an uploaded image, or the image of a shape in uploaded data, can have.
+	// It's checked before decoding the image, and larger images get a 413 response.
+	MaxImagePixels int64
+	// The most bytes that compressed binary data can decompress to. Larger data gets a 413 response.
+	MaxDecompressedSize int64
+	// How many requests are handled at the same time. Others wait for one of them to finish, until they time out.
+	MaxConcurrentRequests int
+	// How long a request can take before it gets a 503 response.
+	// Creating shapes can't be stopped part way, so a request that times out still takes up its place among
+	// the concurrent requests until it finishes.
+	Timeout time.Duration
+}
+
+// Any limit that's 0 or less is left out.
+var DefaultOptions = Options{
+	MaxRequestSize:        32 << 20,
+	MaxImagePixels:        50_000_000,
+	MaxDecompressedSize:   256 << 20,
+	MaxConcurrentRequests: runtime.NumCPU(),
+	Timeout:               time.Minute,
+}
+
+type handler struct {
+	options Options
+}
+
+// Returns a handler for the endpoints, which can be mounted under a prefix with [http.StripPrefix].
+func NewHandler(options *Options) http.Handler {
+	if options == nil {
+		options = &DefaultOptions
+	}
+	h := &handler{options: *options}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /generate", h.generate)
+	mux.HandleFunc("POST /simplify", h.simplify)
+	mux.HandleFunc("POST /reserialize", h.reserialize)
+
+	var handler http.Handler = mux
+	if options.MaxConcurrentRequests > 0 {
+		handler = limitConcurrency(handler, options.MaxConcurrentRequests)
+	}
+	if options.Timeout <= 0 {
+		return handler
+	}
+	return http.TimeoutHandler(handler, options.Timeout, "request timed out")
+}
+
+// Handles at most n requests at the same time. The rest wait until there's room,
+// or give up if they time out or the client goes away first.
+func limitConcurrency(next http.Handler, n int) http.Handler {
+	slots := make(chan struct{}, n)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func (h *handler) generate(w http.ResponseWriter, r *http.Request) {
+	img, sourceSize, ok := h.readImage(w, r)
+	if !ok {
+		return
+	}
+	epsilon, err := parseFloatParameter(r, "epsilon")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data := boardshapes.CreateShapes(img, boardshapes.ShapeCreationOptions{EpsilonRDP: epsilon})
+	data.SetSourceSize(sourceSize.X, sourceSize.Y)
+	writeData(w, r, data)
+}
+
+func (h *handler) simplify(w http.ResponseWriter, r *http.Request) {
+	img, _, ok := h.readImage(w, r)
+	if !ok {
+		return
+	}
+	epsilon, err := parseFloatParameter(r, "epsilon")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	simplified := boardshapes.SimplifyImage(img, boardshapes.ShapeCreationOptions{EpsilonRDP: epsilon})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, simplified); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+func (h *handler) reserialize(w http.ResponseWriter, r *http.Request) {
+	upload, ok := h.readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	options := map[string]any{"maxImagePixels": int(max(0, h.options.MaxImagePixels))}
+	if h.options.MaxDecompressedSize > 0 {
+		options["maxDecompressedSize"] = h.options.MaxDecompressedSize
+	}
+	var data *boardshapes.BoardshapesData
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(upload), []byte("{")) {
+		data, err = serialization.JsonDeserialize(bytes.NewReader(upload), options)
+	} else {
+		data, err = serialization.BinaryDeserialize(bytes.NewReader(upload), options)
+	}
+	if errors.Is(err, serialization.ErrDecompressedTooLarge) {
+		http.Error(w, fmt.Sprintf("data is larger than %d bytes once decompressed", h.options.MaxDecompressedSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, serialization.ErrImageTooLarge) {
+		http.Error(w, fmt.Sprintf("data has a shape image with more than %d pixels", h.options.MaxImagePixels), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeData(w, r, data)
+}
+
+// Reads the uploaded image and resizes it if the request asks for it. Also returns its size before resizing.
+// If it can't, it responds with an error and returns false.
+func (h *handler) readImage(w http.ResponseWriter, r *http.Request) (image.Image, image.Point, bool) {
+	upload, ok := h.readUpload(w, r)
+	if !ok {
+		return nil, image.Point{}, false
+	}
+	// check the size first, since decoding allocates all of its pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(upload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, image.Point{}, false
+	}
+	if limit := h.options.MaxImagePixels; limit > 0 && int64(config.Width)*int64(config.Height) > limit {
+		http.Error(w, fmt.Sprintf("image has more than %d pixels", limit), http.StatusRequestEntityTooLarge)
+		return nil, image.Point{}, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(upload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, image.Point{}, false
+	}
+	sourceSize := img.Bounds().Size()
+
+	if !r.URL.Query().Has("resize") {
+		return img, sourceSize, true
+	}
+	width, height, err := parseResize(r.URL.Query().Get("resize"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, image.Point{}, false
+	}
+	if width == 0 && height == 0 {
+		img = boardshapes.ResizeImage(img)
+	} else {
+		img = boardshapes.ResizeImageTo(img, width, height)
+	}
+	return img, sourceSize, true
+}
+
+// Reads the uploaded file, from the "file" field of a multipart form or else the whole body.
+// If it can't, it responds with an error and returns false.
+func (h *handler) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if h.options.MaxRequestSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.options.MaxRequestSize)
+	}
+
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondWithReadError(w, err)
+			return nil, false
+		}
+		defer file.Close()
+		body = file
+	}
+
+	upload, err := io.ReadAll(body)
+	if err != nil {
+		respondWithReadError(w, err)
+		return nil, false
+	}
+	if len(upload) == 0 {
+		http.Error(w, "no file uploaded", http.StatusBadRequest)
+		return nil, false
+	}
+	return upload, true
+}
+
+func respondWithReadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("request is larger than %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// Serializes the data to binary or JSON, depending on the request.
+func writeData(w http.ResponseWriter, r *http.Request, data *boardshapes.BoardshapesData) {
+	binary, err := parseBoolParameter(r, "binary")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	compress, err := parseBoolParameter(r, "compress")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// serialize everything first, so an error can still get an error response
+	var buf bytes.Buffer
+	if binary {
+		options := serialization.DefaultOptions
+		if compress {
+			options.Compression = serialization.COMPRESSION_FLATE
+		}
+		err = serialization.BinarySerialize(&buf, data, &options)
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		err = serialization.JsonSerialize(&buf, data)
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+// Parses a size in the format [width]x[height], where either can be left out. Both are 0 if the size is empty.
+func parseResize(value string) (width, height int, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	w, h, found := strings.Cut(value, "x")
+	if !found {
+		return 0, 0, ErrInvalidResize
+	}
+	if w != "" {
+		if width, err = strconv.Atoi(w); err != nil {
+			return 0, 0, ErrInvalidResize
+		}
+	}
+	if h != "" {
+		if height, err = strconv.Atoi(h); err != nil {
+			return 0, 0, ErrInvalidResize
+		}
+	}
+	return width, height, nil
+}
+
+func parseFloatParameter(r *http.Request, name string) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return f, nil
+}
+
+func parseBoolParameter(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return b, nil
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/draw"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/boardshapes/boardshapes/serialization"
+)
+
+func testImage(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 20, 80, 80), image.Black, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerate(t *testing.T) {
+	handler := NewHandler(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/generate?binary=true&resize=100x", bytes.NewReader(testImage(t)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /generate status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	data, err := serialization.BinaryDeserialize(rec.Body, nil)
+	if err != nil {
+		t.Fatalf("BinaryDeserialize() error = %v", err)
+	}
+	if len(data.Shapes) != 1 {
+		t.Errorf("POST /generate returned %d shapes, want 1", len(data.Shapes))
+	}
+	if m := data.Metadata; m == nil || m.ImageWidth != 100 || m.SourceWidth != 200 || m.SourceHeight != 100 || m.Scale != 0.5 {
+		t.Errorf("POST /generate metadata = %+v, want the 200x100 image resized to half its size", data.Metadata)
+	}
+}
+
+func TestSimplifyMultipart(t *testing.T) {
+	handler := NewHandler(nil)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "board.png")
+	file.Write(testImage(t))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/simplify", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /simplify status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if _, err := png.Decode(rec.Body); err != nil {
+		t.Errorf("POST /simplify didn't return a PNG: %v", err)
+	}
+}
+
+func TestReserialize(t *testing.T) {
+	handler := NewHandler(nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/generate", bytes.NewReader(testImage(t))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /generate status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	// JSON in, binary out
+	req := httptest.NewRequest(http.MethodPost, "/reserialize?binary=true&compress=true", rec.Body)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /reserialize status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("POST /reserialize Content-Type = %q, want application/octet-stream", got)
+	}
+	compressed := rec.Body.Bytes()
+	if _, err := serialization.BinaryDeserialize(bytes.NewReader(compressed), nil); err != nil {
+		t.Errorf("BinaryDeserialize() error = %v", err)
+	}
+
+	handler = NewHandler(&Options{MaxDecompressedSize: 100})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/reserialize", bytes.NewReader(compressed)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /reserialize of data too large once decompressed status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestReserializeLimits(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/generate?binary=true", bytes.NewReader(testImage(t))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /generate status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	// the shape is a 60x60 square, stored as a mask
+	withMasks := rec.Body.Bytes()
+
+	rec = httptest.NewRecorder()
+	NewHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/generate", bytes.NewReader(testImage(t))))
+	// and as a PNG image
+	withImages := rec.Body.Bytes()
+
+	// a color table chunk that claims 2^32-1 colors in 4 bytes
+	bogusCount := append([]byte{serialization.CHUNK_VERSION}, "0.2.0\x00"...)
+	bogusCount = append(bogusCount, serialization.CHUNK_COLOR_TABLE, 0, 0, 0, 4, 0xff, 0xff, 0xff, 0xff)
+
+	tests := []struct {
+		name    string
+		options Options
+		body    []byte
+		want    int
+	}{
+		{"bogus count", DefaultOptions, bogusCount, http.StatusBadRequest},
+		{"mask too large", Options{MaxImagePixels: 1000}, withMasks, http.StatusRequestEntityTooLarge},
+		{"mask small enough", Options{MaxImagePixels: 3600}, withMasks, http.StatusOK},
+		{"shape image too large", Options{MaxImagePixels: 1000}, withImages, http.StatusRequestEntityTooLarge},
+		{"shape image small enough", Options{MaxImagePixels: 3600}, withImages, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewHandler(&tt.options).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/reserialize", bytes.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("POST /reserialize status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	started, release := make(chan bool), make(chan bool)
+	handler := limitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+	}), 1)
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/generate", nil))
+	<-started
+
+	// the only slot is taken, so this one gives up once its context is done instead of being handled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/generate", nil).WithContext(ctx))
+	release <- true
+}
+
+func TestBadRequests(t *testing.T) {
+	handler := NewHandler(&Options{MaxRequestSize: 1000})
+	img := testImage(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   []byte
+		want   int
+	}{
+		{"too large", http.MethodPost, "/generate", bytes.Repeat([]byte{0}, 2000), http.StatusRequestEntityTooLarge},
+		{"not an image", http.MethodPost, "/generate", []byte("not an image"), http.StatusBadRequest},
+		{"empty", http.MethodPost, "/simplify", nil, http.StatusBadRequest},
+		{"invalid resize", http.MethodPost, "/simplify?resize=big", img, http.StatusBadRequest},
+		{"invalid epsilon", http.MethodPost, "/generate?epsilon=x", img, http.StatusBadRequest},
+		{"invalid data", http.MethodPost, "/reserialize", []byte("{"), http.StatusBadRequest},
+		{"wrong method", http.MethodGet, "/generate", nil, http.StatusMethodNotAllowed},
+		{"unknown endpoint", http.MethodPost, "/render", img, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, bytes.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+			}
+		})
+	}
+
+	handler = NewHandler(&Options{MaxImagePixels: 100 * 100})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/generate", bytes.NewReader(img)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /generate of an image with too many pixels status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -46,6 +46,60 @@ func (v1 Vertex) DirectionTo(v2 Vertex) (x, y float64) {
 	return (answerX / mag), (answerY / mag)
 }
 
+// Returns twice the signed area of the closed path.
+// With the y axis pointing down, a positive area means the path runs clockwise on screen.
+func signedArea(path []Vertex) int {
+	area := 0
+	for i := range path {
+		a, b := path[i], path[(i+1)%len(path)]
+		area += int(a.X)*int(b.Y) - int(b.X)*int(a.Y)
+	}
+	return area
+}
+
+// Whether most of the inner path is inside the closed outer path. Vertices on the outer path's edges don't count.
+func isPathInside(inner, outer []Vertex) bool {
+	inside, outside := 0, 0
+	for _, v := range inner {
+		switch {
+		case isOnPath(outer, v):
+		case containsVertex(outer, v):
+			inside++
+		default:
+			outside++
+		}
+	}
+	return inside >= outside
+}
+
+// Whether the vertex is inside the closed path, by the even-odd rule.
+func containsVertex(path []Vertex, v Vertex) bool {
+	inside := false
+	x, y := int(v.X), int(v.Y)
+	for i := range path {
+		a, b := path[i], path[(i+1)%len(path)]
+		ax, ay, bx, by := int(a.X), int(a.Y), int(b.X), int(b.Y)
+		if (ay > y) != (by > y) && ((x-ax)*(by-ay) < (bx-ax)*(y-ay)) == (by > ay) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Whether the vertex is on one of the edges of the closed path.
+func isOnPath(path []Vertex, v Vertex) bool {
+	x, y := int(v.X), int(v.Y)
+	for i := range path {
+		a, b := path[i], path[(i+1)%len(path)]
+		ax, ay, bx, by := int(a.X), int(a.Y), int(b.X), int(b.Y)
+		if (bx-ax)*(y-ay) == (by-ay)*(x-ax) &&
+			x >= min(ax, bx) && x <= max(ax, bx) && y >= min(ay, by) && y <= max(ay, by) {
+			return true
+		}
+	}
+	return false
+}
+
 func forNonDiagonalAdjacents(x, y uint16, maxX, maxY int, function func(x, y uint16)) {
 	if y > 0 {
 		function(x, y-1)
@@ -0,0 +1,64 @@
+package boardshapes
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Returns the path in Well-Known Text, as a polygon if it's closed or as a line string if it isn't.
+func (p Path) WKT(closed bool) string {
+	if closed {
+		return wktGeometry("POLYGON", []Path{p}, 0, 0, true)
+	}
+	return wktGeometry("LINESTRING", []Path{p}, 0, 0, false)
+}
+
+// Returns the shape in Well-Known Text, as a polygon (with its holes) or, if it's open, a line string.
+// Unlike its path, the coordinates are where the shape is in the image.
+func (sd ShapeData) WKT() string {
+	if sd.Open {
+		return wktGeometry("LINESTRING", []Path{sd.Path}, sd.CornerX, sd.CornerY, false)
+	}
+	return wktGeometry("POLYGON", append([]Path{sd.Path}, sd.Holes...), sd.CornerX, sd.CornerY, true)
+}
+
+// Writes the rings of a polygon (or the one line of a line string), offset by x and y.
+// Empty holes are left out.
+func wktGeometry(geometryType string, paths []Path, x, y int, closed bool) string {
+	if len(paths[0]) == 0 {
+		return geometryType + " EMPTY"
+	}
+
+	var b strings.Builder
+	b.WriteString(geometryType)
+	b.WriteString(" ")
+	if closed {
+		b.WriteString("(")
+	}
+	for i, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		if closed {
+			// rings end where they start
+			path = append(path[:len(path):len(path)], path[0])
+		}
+		for j, v := range path {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Itoa(int(v.X) + x))
+			b.WriteString(" ")
+			b.WriteString(strconv.Itoa(int(v.Y) + y))
+		}
+		b.WriteString(")")
+	}
+	if closed {
+		b.WriteString(")")
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package boardshapes
+
+import "testing"
+
+func TestPath_WKT(t *testing.T) {
+	path := Path{{0, 0}, {10, 0}, {10, 5}}
+	if got, want := path.WKT(true), "POLYGON ((0 0, 10 0, 10 5, 0 0))"; got != want {
+		t.Errorf("Path.WKT(true) = %q, want %q", got, want)
+	}
+	if got, want := path.WKT(false), "LINESTRING (0 0, 10 0, 10 5)"; got != want {
+		t.Errorf("Path.WKT(false) = %q, want %q", got, want)
+	}
+	if got, want := (Path{}).WKT(true), "POLYGON EMPTY"; got != want {
+		t.Errorf("Path.WKT(true) = %q, want %q", got, want)
+	}
+}
+
+func TestShapeData_WKT(t *testing.T) {
+	shape := ShapeData{
+		CornerX: 100,
+		CornerY: 200,
+		Path:    Path{{0, 0}, {30, 0}, {30, 30}, {0, 30}},
+		Holes:   []Path{{{10, 10}, {10, 20}, {20, 20}}},
+	}
+	want := "POLYGON ((100 200, 130 200, 130 230, 100 230, 100 200), (110 210, 110 220, 120 220, 110 210))"
+	if got := shape.WKT(); got != want {
+		t.Errorf("ShapeData.WKT() = %q, want %q", got, want)
+	}
+
+	shape.Holes = append(shape.Holes, Path{})
+	if got := shape.WKT(); got != want {
+		t.Errorf("ShapeData.WKT() with an empty hole = %q, want %q", got, want)
+	}
+
+	shape.Open = true
+	if got, want := shape.WKT(), "LINESTRING (100 200, 130 200, 130 230, 100 230)"; got != want {
+		t.Errorf("ShapeData.WKT() = %q, want %q", got, want)
+	}
+}